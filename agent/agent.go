@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/relistan/go-director"
+	"go.uber.org/zap"
+
+	"github.com/dselans/pidstat/config"
+	"github.com/dselans/pidstat/deps"
+	"github.com/dselans/pidstat/fleet"
+	"github.com/dselans/pidstat/stat"
+	"github.com/dselans/pidstat/util"
+)
+
+const (
+	DefaultPushInterval = 5 * time.Second
+	PushTimeout         = 30 * time.Second
+
+	// Path of the push endpoint on the server
+	PushPath = "/api/agent/push"
+)
+
+var (
+	sugar *zap.SugaredLogger
+)
+
+func init() {
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "agent"})
+	if err != nil {
+		panic(fmt.Sprintf("unable to setup logger: %v", err))
+	}
+
+	sugar = logger.Sugar()
+}
+
+// Agent pushes the process list + samples of all watches to a central server
+// and executes the commands it gets back
+type Agent struct {
+	dependencies *deps.Dependencies
+	url          string
+	host         string
+	token        string
+	version      string
+	interval     time.Duration
+	client       *http.Client
+
+	// Next offset per watched pid; only samples after it get pushed
+	offsets map[int32]int
+
+	// Start of the watch the offset belongs to
+	watchedSince map[int32]time.Time
+}
+
+func New(cfg *config.Config, version string, d *deps.Dependencies) (*Agent, error) {
+	if cfg.AgentServer == "" {
+		return nil, errors.New("no server configured")
+	}
+
+	host := cfg.AgentHost
+
+	if host == "" {
+		var err error
+
+		host, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine hostname: %v", err)
+		}
+	}
+
+	interval := time.Duration(cfg.AgentPushInterval)
+	if interval == 0 {
+		interval = DefaultPushInterval
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.AgentInsecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Agent{
+		dependencies: d,
+		url:          strings.TrimRight(cfg.AgentServer, "/") + PushPath,
+		host:         host,
+		token:        cfg.AgentToken,
+		version:      version,
+		interval:     interval,
+		client:       &http.Client{Timeout: PushTimeout, Transport: transport},
+		offsets:      make(map[int32]int, 0),
+		watchedSince: make(map[int32]time.Time, 0),
+	}, nil
+}
+
+// Run pushes on every interval; blocks forever
+func (a *Agent) Run() error {
+	sugar.Infof("pushing to '%v' as '%v' every %v", a.url, a.host, a.interval)
+
+	looper := director.NewImmediateTimedLooper(director.FOREVER, a.interval, nil)
+
+	looper.Loop(func() error {
+		if err := a.push(); err != nil {
+			sugar.Errorf("unable to push to '%v': %v", a.url, err)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+func (a *Agent) push() error {
+	statter := a.dependencies.Statter
+
+	processes, err := statter.GetProcesses()
+	if err != nil {
+		return fmt.Errorf("unable to get process list: %v", err)
+	}
+
+	watched, err := statter.GetWatchedProcesses()
+	if err != nil {
+		return fmt.Errorf("unable to get watched processes: %v", err)
+	}
+
+	push := fleet.Push{
+		Host:         a.host,
+		Version:      a.version,
+		PushInterval: stat.Duration(a.interval),
+		Processes:    processes,
+		Watches:      make([]stat.ProcInfo, 0, len(watched)),
+	}
+
+	// Offsets are only advanced once the server has the samples
+	offsets := make(map[int32]int, len(watched))
+	watchedSince := make(map[int32]time.Time, len(watched))
+
+	for _, w := range watched {
+		procInfo, err := statter.GetStatsForPID(w.PID, a.offsets[w.PID])
+		if err == stat.InvalidOffsetErr || (err == nil && restarted(procInfo, a.watchedSince[w.PID])) {
+			// Watch was restarted since the last push
+			procInfo, err = statter.GetStatsForPID(w.PID, 0)
+		}
+
+		if err != nil {
+			// Watch ended in the meantime
+			continue
+		}
+
+		// Buckets + tree data stay local
+		procInfo.Buckets = nil
+		procInfo.TreeMetrics = nil
+		procInfo.TreeBuckets = nil
+		procInfo.Children = nil
+
+		push.Watches = append(push.Watches, procInfo)
+		offsets[w.PID] = procInfo.NextOffset
+
+		if procInfo.WatchedSince != nil {
+			watchedSince[w.PID] = *procInfo.WatchedSince
+		}
+	}
+
+	response, err := a.send(push)
+	if err != nil {
+		return err
+	}
+
+	a.offsets = offsets
+	a.watchedSince = watchedSince
+
+	for _, command := range response.Commands {
+		a.execute(command)
+	}
+
+	return nil
+}
+
+// Is 'procInfo' from a different watch than the one that was pushed last?
+func restarted(procInfo stat.ProcInfo, watchedSince time.Time) bool {
+	return procInfo.WatchedSince != nil && !procInfo.WatchedSince.Equal(watchedSince)
+}
+
+func (a *Agent) send(push fleet.Push) (fleet.PushResponse, error) {
+	data, err := json.Marshal(push)
+	if err != nil {
+		return fleet.PushResponse{}, fmt.Errorf("unable to encode push: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(data))
+	if err != nil {
+		return fleet.PushResponse{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fleet.PushResponse{}, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fleet.PushResponse{}, fmt.Errorf("unable to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fleet.PushResponse{}, fmt.Errorf("server responded with %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	response := fleet.PushResponse{}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fleet.PushResponse{}, fmt.Errorf("unable to decode response: %v", err)
+	}
+
+	return response, nil
+}
+
+func (a *Agent) execute(command fleet.Command) {
+	statter := a.dependencies.Statter
+
+	var err error
+
+	switch command.Action {
+	case fleet.ActionStart:
+		err = statter.StartWatchProcess(command.PID, command.Config)
+	case fleet.ActionUpdate:
+		err = statter.SetWatchInterval(command.PID, time.Duration(command.Config.Interval))
+	case fleet.ActionStop:
+		err = statter.StopWatchProcess(command.PID)
+	default:
+		err = fmt.Errorf("unknown action '%v'", command.Action)
+	}
+
+	if err != nil {
+		sugar.Errorf("unable to %v watch for pid '%v': %v", command.Action, command.PID, err)
+		return
+	}
+
+	sugar.Infof("%v watch for pid '%v' (requested by server)", command.Action, command.PID)
+}
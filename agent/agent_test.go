@@ -0,0 +1,269 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/dselans/pidstat/api"
+	"github.com/dselans/pidstat/config"
+	"github.com/dselans/pidstat/deps"
+	"github.com/dselans/pidstat/fleet"
+	"github.com/dselans/pidstat/stat"
+)
+
+const testHost = "test-host"
+
+// Starts the API in server mode on a free localhost port; returns its base URL
+func startServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+
+	cfg := config.Default()
+	cfg.ListenAddress = address
+
+	d, err := deps.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Fleet, err = fleet.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := api.New(cfg, "test", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go a.Run()
+
+	url := "http://" + address
+
+	waitFor(t, "server to come up", func() bool {
+		resp, err := http.Get(url + "/api/hosts")
+		if err != nil {
+			return false
+		}
+
+		resp.Body.Close()
+
+		return resp.StatusCode == http.StatusOK
+	})
+
+	return url
+}
+
+func newTestAgent(t *testing.T, url string) *Agent {
+	cfg := config.Default()
+	cfg.AgentServer = url
+	cfg.AgentHost = testHost
+
+	d, err := deps.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(cfg, "test", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func request(t *testing.T, method, url string, v interface{}) int {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("unable to decode response of %v %v: %v", method, url, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func isWatched(t *testing.T, a *Agent, pid int32) bool {
+	watched, err := a.dependencies.Statter.GetWatchedProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range watched {
+		if w.PID == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestAgentPushesToServer(t *testing.T) {
+	url := startServer(t)
+	a := newTestAgent(t, url)
+
+	statter := a.dependencies.Statter
+	pid := int32(os.Getpid())
+
+	if err := statter.StartWatchProcess(pid, stat.WatchConfig{Interval: stat.Duration(100 * time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "samples", func() bool {
+		procInfo, err := statter.GetStatsForPID(pid, 0)
+		return err == nil && len(procInfo.Metrics) >= 3
+	})
+
+	if err := a.push(); err != nil {
+		t.Fatalf("unable to push: %v", err)
+	}
+
+	pushed := a.offsets[pid]
+
+	// Host is listed
+	hosts := make([]fleet.HostInfo, 0)
+
+	if code := request(t, http.MethodGet, url+"/api/hosts", &hosts); code != http.StatusOK {
+		t.Fatalf("GET /api/hosts: %v", code)
+	}
+
+	if len(hosts) != 1 || hosts[0].Name != testHost || !hosts[0].Online || hosts[0].Watched != 1 {
+		t.Fatalf("unexpected hosts: %+v", hosts)
+	}
+
+	// Pushed samples are served
+	local, err := statter.GetStatsForPID(pid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	procURL := fmt.Sprintf("%v/api/hosts/%v/process/%v", url, testHost, pid)
+
+	var remote stat.ProcInfo
+
+	if code := request(t, http.MethodGet, procURL, &remote); code != http.StatusOK {
+		t.Fatalf("GET %v: %v", procURL, code)
+	}
+
+	if len(remote.Metrics) != pushed || pushed == 0 {
+		t.Fatalf("expected %v pushed samples, got %v", pushed, len(remote.Metrics))
+	}
+
+	for i, m := range remote.Metrics {
+		if !m.Timestamp.Equal(local.Metrics[i].Timestamp) || m.RSS != local.Metrics[i].RSS {
+			t.Fatalf("sample %v differs: pushed %+v, local %+v", i, m, local.Metrics[i])
+		}
+	}
+
+	if !remote.Watched {
+		t.Errorf("expected pid '%v' to be watched on the server", pid)
+	}
+
+	// Time range queries work the same as locally
+	var limited stat.ProcInfo
+
+	if code := request(t, http.MethodGet, procURL+"?limit=1", &limited); code != http.StatusOK {
+		t.Fatalf("GET %v?limit=1: %v", procURL, code)
+	}
+
+	if len(limited.Metrics) != 1 || !limited.Metrics[0].Timestamp.Equal(remote.Metrics[len(remote.Metrics)-1].Timestamp) {
+		t.Errorf("expected only the newest sample with limit=1, got %+v", limited.Metrics)
+	}
+
+	if code := request(t, http.MethodGet, procURL+"?limit=1&offset=1", nil); code != http.StatusBadRequest {
+		t.Errorf("expected offset + limit to be rejected, got %v", code)
+	}
+
+	// Queued stop reaches the agent with the next push
+	if code := request(t, http.MethodDelete, procURL, nil); code != http.StatusAccepted {
+		t.Fatalf("DELETE %v: %v", procURL, code)
+	}
+
+	if !isWatched(t, a, pid) {
+		t.Fatal("watch stopped before the agent pushed")
+	}
+
+	if err := a.push(); err != nil {
+		t.Fatalf("unable to push: %v", err)
+	}
+
+	if isWatched(t, a, pid) {
+		t.Error("queued stop was not executed by the agent")
+	}
+
+	// ... and so does a queued start
+	cmd := exec.Command("sleep", "30")
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	child := int32(cmd.Process.Pid)
+	childURL := fmt.Sprintf("%v/api/hosts/%v/process/%v", url, testHost, child)
+
+	if code := request(t, http.MethodPost, childURL, nil); code != http.StatusAccepted {
+		t.Fatalf("POST %v: %v", childURL, code)
+	}
+
+	if err := a.push(); err != nil {
+		t.Fatalf("unable to push: %v", err)
+	}
+
+	if !isWatched(t, a, child) {
+		t.Fatal("queued start was not executed by the agent")
+	}
+
+	// Stopped watch is kept (inactive), the new one shows up after the next push
+	if err := a.push(); err != nil {
+		t.Fatalf("unable to push: %v", err)
+	}
+
+	var started, stopped stat.ProcInfo
+
+	if code := request(t, http.MethodGet, childURL, &started); code != http.StatusOK || !started.Watched {
+		t.Errorf("expected pid '%v' to be watched on the server (status %v)", child, code)
+	}
+
+	if code := request(t, http.MethodGet, procURL, &stopped); code != http.StatusOK || stopped.Watched {
+		t.Errorf("expected pid '%v' to no longer be watched on the server (status %v)", pid, code)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get alerts
+// @Description Get all firing alerts and recently resolved ones (newest first)
+// @Tags alerts
+// @Produce json
+// @Param state query string false "Only return alerts in this state (firing or resolved)"
+// @Success 200 {array} stat.Alert "Contains zero or more alerts"
+// @Failure 400 {object} api.StatusResponse "Invalid state"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/alerts [get]
+func (a *API) getAlerts(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+
+	if state != "" && state != stat.AlertFiring && state != stat.AlertResolved {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("invalid state '%v' (expected '%v' or '%v')", state, stat.AlertFiring, stat.AlertResolved),
+		})
+
+		return
+	}
+
+	alerts, err := a.dependencies.Statter.GetAlerts()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	if state != "" {
+		filtered := make([]stat.Alert, 0)
+
+		for _, alert := range alerts {
+			if alert.State == state {
+				filtered = append(filtered, alert)
+			}
+		}
+
+		alerts = filtered
+	}
+
+	render.JSON(w, http.StatusOK, alerts)
+}
+
+// @Summary Get all alert rules
+// @Description Get all alert rules; rules are evaluated against every new sample of the watches they apply to
+// @Tags alerts
+// @Produce json
+// @Success 200 {array} stat.AlertRule "Contains zero or more alert rules"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/alerts/rules [get]
+func (a *API) getAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := a.dependencies.Statter.GetAlertRules()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, rules)
+}
+
+// @Summary Add an alert rule
+// @Description Add an alert rule. 'metric' is the JSON name of a sample field (ie. 'rss', 'cpu', 'threads'); the most
+// @Description recent 'samples' samples are combined via 'aggregate' (last, avg or delta) and compared against
+// @Description 'threshold' using 'op' (>, >=, <, <=). The alert fires once the condition has held for 'for' (ie. '1m')
+// @Description and resolves as soon as it no longer holds (or the watch ends).
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param rule body stat.AlertRule true "Alert rule (id is generated if not set)"
+// @Success 200 {object} stat.AlertRule "Created alert rule"
+// @Failure 400 {object} api.StatusResponse "Invalid alert rule"
+// @Failure 409 {object} api.StatusResponse "Alert rule with this ID already exists"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/alerts/rules [post]
+func (a *API) addAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := stat.AlertRule{}
+
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to decode alert rule: %v", err),
+		})
+
+		return
+	}
+
+	if err := rule.Validate(); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("invalid alert rule: %v", err),
+		})
+
+		return
+	}
+
+	created, err := a.dependencies.Statter.AddAlertRule(rule)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to add alert rule: %v", err)
+
+		if err == stat.AlertRuleExistsErr {
+			statusCode = http.StatusConflict
+			errorMessage = fmt.Sprintf("alert rule '%v' already exists", rule.ID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+		return
+	}
+
+	render.JSON(w, http.StatusOK, created)
+}
+
+// @Summary Delete an alert rule
+// @Description Delete an alert rule by ID; alerts firing for it are resolved
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert rule ID"
+// @Success 200 {object} api.StatusResponse "Alert rule has been deleted"
+// @Failure 404 {object} api.StatusResponse "Alert rule does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/alerts/rules/{id} [delete]
+func (a *API) deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := a.dependencies.Statter.DeleteAlertRule(id); err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to delete alert rule '%v': %v", id, err)
+
+		if err == stat.AlertRuleNotFoundErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("alert rule '%v' does not exist", id)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+		return
+	}
+
+	render.JSON(w, http.StatusOK, StatusResponse{
+		Status:  "ok",
+		Message: fmt.Sprintf("alert rule '%v' deleted", id),
+	})
+}
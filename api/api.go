@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 
@@ -11,6 +12,7 @@ import (
 	renderPkg "github.com/unrolled/render"
 	"go.uber.org/zap"
 
+	"github.com/dselans/pidstat/config"
 	"github.com/dselans/pidstat/deps"
 	_ "github.com/dselans/pidstat/docs"
 	"github.com/dselans/pidstat/util"
@@ -22,7 +24,7 @@ var (
 )
 
 func init() {
-	logger, err := util.CreateLogger(false, map[string]interface{}{"pkg": "api"})
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "api"})
 	if err != nil {
 		panic(fmt.Sprintf("unable to setup logger: %v", err))
 	}
@@ -34,16 +36,18 @@ func init() {
 }
 
 type API struct {
-	listenAddress string
-	dependencies  *deps.Dependencies
-	version       string
+	config       *config.Config
+	dependencies *deps.Dependencies
+	version      string
 }
 
-func New(listenAddress, version string, d *deps.Dependencies) (*API, error) {
+// New creates the web API; listen address, TLS, CORS + credentials are taken
+// from 'cfg' (changes to them require a restart)
+func New(cfg *config.Config, version string, d *deps.Dependencies) (*API, error) {
 	return &API{
-		listenAddress: listenAddress,
-		dependencies:  d,
-		version:       version,
+		config:       cfg,
+		dependencies: d,
+		version:      version,
 	}, nil
 }
 
@@ -53,14 +57,25 @@ func (a *API) Run() error {
 	// Output apache-style access logs
 	r.Use(middleware.Logger)
 
-	// CORS
+	// CORS; credentials are only allowed for explicitly listed origins
+	origins := a.config.CORSOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+
 	corsMW := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{TotalCountHeader, NextCursorHeader},
+		AllowCredentials: len(a.config.CORSOrigins) > 0,
 	})
 
 	r.Use(corsMW.Handler)
 
+	// Everything (incl. UI + docs) requires credentials if any are configured
+	r.Use(a.authenticate)
+
 	// Serve static files
 	r.Get("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.FileServer(a.dependencies.PackrBox).ServeHTTP(w, r)
@@ -68,16 +83,83 @@ func (a *API) Run() error {
 
 	r.Get("/docs/*", httpSwagger.WrapHandler)
 
+	// Prometheus exporter
+	r.Get("/metrics", a.getMetrics)
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/version", a.getVersion)
 		r.Get("/process", a.getProcesses)
+		r.Get("/process/tree", a.getProcessTree)
+		r.Get("/process/history", a.getFinishedWatches)
 		r.Get("/process/{id}", a.getProcess)
 		r.Post("/process/{id}", a.startProcessWatch)
+		r.Put("/process/{id}", a.updateProcessWatch)
 		r.Delete("/process/{id}", a.stopProcessWatch)
+		r.Get("/process/{id}/stream", a.streamProcessSSE)
+		r.Get("/process/{id}/ws", a.streamProcessWebSocket)
+		r.Get("/process/{id}/report", a.getProcessReport)
+		r.Get("/process/{id}/summary", a.getProcessSummary)
+		r.Get("/process/{id}/fds", a.getProcessFDs)
+		r.Get("/process/{id}/threads", a.getProcessThreads)
+		r.Get("/process/{id}/history", a.getProcessHistory)
+		r.Delete("/process/{id}/history", a.deleteProcessHistory)
+		r.Get("/rules", a.getRules)
+		r.Post("/rules", a.addRule)
+		r.Delete("/rules/{id}", a.deleteRule)
+		r.Get("/alerts", a.getAlerts)
+		r.Get("/alerts/rules", a.getAlertRules)
+		r.Post("/alerts/rules", a.addAlertRule)
+		r.Delete("/alerts/rules/{id}", a.deleteAlertRule)
+
+		// Server mode: agent pushes + processes of every host
+		if a.dependencies.Fleet != nil {
+			r.Post("/agent/push", a.pushAgent)
+			r.Get("/hosts", a.getHosts)
+			r.Get("/hosts/{host}/process", a.getHostProcesses)
+			r.Get("/hosts/{host}/process/tree", a.getHostProcessTree)
+			r.Get("/hosts/{host}/process/{id}", a.getHostProcess)
+			r.Post("/hosts/{host}/process/{id}", a.startHostProcessWatch)
+			r.Put("/hosts/{host}/process/{id}", a.updateHostProcessWatch)
+			r.Delete("/hosts/{host}/process/{id}", a.stopHostProcessWatch)
+			r.Get("/hosts/{host}/process/{id}/summary", a.getHostProcessSummary)
+			r.Get("/hosts/{host}/process/{id}/report", a.getHostProcessReport)
+
+			// Need the agent's procfs, live samples or exit info (see hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/history", a.hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/{id}/history", a.hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/{id}/stream", a.hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/{id}/ws", a.hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/{id}/fds", a.hostRouteNotSupported)
+			r.Get("/hosts/{host}/process/{id}/threads", a.hostRouteNotSupported)
+		}
 	})
 
-	sugar.Infof("server listening on '%v'", a.listenAddress)
+	server := &http.Server{
+		Addr:    a.config.ListenAddress,
+		Handler: r,
+	}
+
+	if len(a.config.Credentials) == 0 {
+		sugar.Warn("no credentials configured; the API (incl. process cmdlines) is accessible without authentication")
+	}
+
+	if !a.config.TLS() {
+		sugar.Infof("server listening on '%v'", a.config.ListenAddress)
+		return server.ListenAndServe()
+	}
+
+	if a.config.TLSSelfSigned {
+		cert, err := selfSignedCert(a.config.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("unable to generate self-signed certificate: %v", err)
+		}
+
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	sugar.Infof("server listening on '%v' (TLS)", a.config.ListenAddress)
 
-	return http.ListenAndServe(a.listenAddress, r)
+	// Cert + key are empty (and ignored) if TLSConfig already has a certificate
+	return server.ListenAndServeTLS(a.config.TLSCert, a.config.TLSKey)
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/dselans/pidstat/config"
+)
+
+// Only checked if credentials are configured; read-only credentials are
+// limited to safe methods (GET, HEAD, OPTIONS)
+func (a *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(a.config.Credentials) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role, ok := a.role(r)
+		if !ok {
+			// Lets browsers prompt for basic auth (ie. for the UI)
+			if a.basicAuth() {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pidstat"`)
+			}
+
+			render.JSON(w, http.StatusUnauthorized, StatusResponse{
+				Status:  "error",
+				Message: "missing or invalid credentials",
+			})
+
+			return
+		}
+
+		if role != config.RoleWrite && !safeMethod(r.Method) {
+			render.JSON(w, http.StatusForbidden, StatusResponse{
+				Status:  "error",
+				Message: "credentials are read-only",
+			})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Role of the credentials passed with the request
+func (a *API) role(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+
+	if strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimPrefix(header, "Bearer ")
+
+		for _, c := range a.config.Credentials {
+			if c.Token != "" && equal(c.Token, token) {
+				return c.Role, true
+			}
+		}
+
+		return "", false
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	for _, c := range a.config.Credentials {
+		if c.Username != "" && equal(c.Username, username) && equal(c.Password, password) {
+			return c.Role, true
+		}
+	}
+
+	return "", false
+}
+
+func (a *API) basicAuth() bool {
+	for _, c := range a.config.Credentials {
+		if c.Username != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// Constant time so credentials cannot be guessed by timing responses
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get open file descriptors of a process
+// @Description List every open fd of a process (watched or not) with its type (file, socket, pipe, anon_inode or
+// @Description other) and target; sockets include their protocol, addresses and (TCP) state. Only the fds of
+// @Description processes owned by the user pidstat runs as can be read (unless it runs as root).
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Success 200 {array} stat.FileDescriptor "Open fds, ordered by fd"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 403 {object} api.StatusResponse "Not permitted to read the fds of the process"
+// @Failure 404 {object} api.StatusResponse "PID does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/fds [get]
+func (a *API) getProcessFDs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	fds, err := a.dependencies.Statter.GetFDsForPID(int32(processID))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := err.Error()
+
+		switch err {
+		case stat.NoProcessErr:
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' does not exist", processID)
+		case stat.FDPermissionErr:
+			statusCode = http.StatusForbidden
+			errorMessage = fmt.Sprintf("not permitted to read the fds of pid '%v'", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, fds)
+}
@@ -1,15 +1,24 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"github.com/dselans/pidstat/stat"
 )
 
+const (
+	// Pagination headers of process list responses
+	TotalCountHeader = "X-Total-Count"
+	NextCursorHeader = "X-Next-Cursor"
+)
+
 var (
 	// Needed for swagger docs
 	_ = stat.ProcInfo{}
@@ -21,20 +30,88 @@ type StatusResponse struct {
 	Message string `json:"message"`
 }
 
+// UpdateWatchRequest changes settings of a running watch
+type UpdateWatchRequest struct {
+	// New sampling interval (ie. "500ms", "1m")
+	Interval stat.Duration `json:"interval"`
+}
+
 // VersionResponse is emitted by the API and contains the build version of the application
 type VersionResponse struct {
 	Version string `json:"version"`
 }
 
 // @Summary Get all running processes
-// @Description Get a list of all running processes; details include PID, name and cmd line args
+// @Description Get a list of all running processes; details include PID, parent PID, name, cmd line args, start time,
+// @Description user, state, CPU usage and RSS.
+// @Description The list can be filtered, sorted and paged through: if there are more results than 'limit', the
+// @Description X-Next-Cursor header contains the 'cursor' for the next page. X-Total-Count contains the number of
+// @Description processes matching the filter.
 // @Tags pid
 // @Produce json
+// @Param name query string false "Only include processes whose name contains this (case-insensitive)"
+// @Param cmdline query string false "Only include processes whose cmd line contains this (case-insensitive)"
+// @Param regex query bool false "Treat 'name' and 'cmdline' as regular expressions"
+// @Param user query string false "Only include processes owned by this user"
+// @Param watched query bool false "Only include watched processes"
+// @Param sort query string false "Sort by pid (default), name, cpu or rss; prefix with '-' for descending order"
+// @Param limit query int false "Return at most this many processes"
+// @Param cursor query string false "Cursor for the next page (from X-Next-Cursor)"
 // @Success 200 {array} stat.ProcInfo "Contains zero or more process entries"
+// @Failure 400 {object} api.StatusResponse "Invalid filter, sort, limit or cursor"
 // @Failure 500 {object} api.StatusResponse "Unexpected server error"
 // @Router /api/process [get]
 func (a *API) getProcesses(w http.ResponseWriter, r *http.Request) {
-	p, err := a.dependencies.Statter.GetProcesses()
+	filter, err := parseProcessFilter(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	page, err := a.dependencies.Statter.FindProcesses(filter)
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	renderProcessPage(w, page)
+}
+
+// @Summary Get the process tree
+// @Description Get all running processes arranged by parent PID; every node contains its children and the summed CPU
+// @Description usage and RSS of its whole subtree. Processes whose parent is not running (ie. init) are at the top level.
+// @Description The filters of /api/process can be used; ancestors of matching processes are kept.
+// @Tags pid
+// @Produce json
+// @Param name query string false "Only include processes whose name contains this (case-insensitive)"
+// @Param cmdline query string false "Only include processes whose cmd line contains this (case-insensitive)"
+// @Param regex query bool false "Treat 'name' and 'cmdline' as regular expressions"
+// @Param user query string false "Only include processes owned by this user"
+// @Param watched query bool false "Only include watched processes"
+// @Success 200 {array} stat.ProcessNode "Contains zero or more top level processes"
+// @Failure 400 {object} api.StatusResponse "Invalid filter"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/tree [get]
+func (a *API) getProcessTree(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseProcessFilter(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	tree, err := a.dependencies.Statter.GetProcessTree(filter)
 	if err != nil {
 		render.JSON(w, http.StatusInternalServerError, StatusResponse{
 			Status:  "error",
@@ -44,18 +121,40 @@ func (a *API) getProcesses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.JSON(w, http.StatusOK, p)
+	render.JSON(w, http.StatusOK, tree)
+}
+
+// Body stays a plain array (as before filtering existed); pagination goes into headers
+func renderProcessPage(w http.ResponseWriter, page stat.ProcessPage) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(page.Total))
+
+	if page.NextCursor != "" {
+		w.Header().Set(NextCursorHeader, page.NextCursor)
+	}
+
+	render.JSON(w, http.StatusOK, page.Processes)
 }
 
 // @Summary Get metrics for a watched process
-// @Description Get metrics for a watched process by ID
+// @Description Get metrics for a watched process by ID. Offset is the absolute sample number since the watch started
+// @Description (use 'next_offset' from the previous response); if it points at samples that have already been
+// @Description downsampled, the retained min/max/avg buckets are included in the response. If persistence is enabled,
+// @Description metrics for watches that have been stopped (or whose process has exited) remain available. For tree
+// @Description watches, 'tree_metrics' (+ 'tree_buckets') holds the summed metrics of the whole tree and 'children' every
+// @Description current descendant.
+// @Description Alternatively, samples can be selected by time via 'from', 'to', 'step' and 'limit' (cannot be combined
+// @Description with 'offset'); downsampled periods are then represented by their bucket averages.
 // @Tags pid
 // @Produce json
 // @Param pid path string true "Process ID (int)"
 // @Param offset query int false "Fetch metrics at offset"
+// @Param from query string false "Only include samples at or after this time (RFC3339 or unix seconds)"
+// @Param to query string false "Only include samples at or before this time (RFC3339 or unix seconds)"
+// @Param step query string false "Resample to one averaged sample per step (ie. '1m' or seconds)"
+// @Param limit query int false "Return at most this many (most recent) samples"
 // @Success 200 {object} stat.ProcInfo "Process metrics"
-// @Failure 400 {object} api.StatusResponse "Invalid PID (not int) or invalid offset (too high)"
-// @Failure 404 {object} api.StatusResponse "PID is not being watched"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int), invalid offset or invalid time range query"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched (and has no stored history)"
 // @Failure 416 {object} api.StatusResponse "Invalid offset (too high)"
 // @Failure 500 {object} api.StatusResponse "Unexpected server error"
 // @Router /api/process/{pid} [get]
@@ -81,9 +180,28 @@ func (a *API) getProcess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, isQuery, err := parseQuery(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
 	// Get QP
 	offsetQueryParam := r.URL.Query().Get("offset")
 
+	if isQuery && offsetQueryParam != "" {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: "offset cannot be combined with from, to, step or limit",
+		})
+
+		return
+	}
+
 	var offset int
 
 	if offsetQueryParam != "" {
@@ -100,7 +218,14 @@ func (a *API) getProcess(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	procInfo, err := a.dependencies.Statter.GetStatsForPID(int32(processID), offset)
+	var procInfo stat.ProcInfo
+
+	if isQuery {
+		procInfo, err = a.dependencies.Statter.QueryStatsForPID(int32(processID), query)
+	} else {
+		procInfo, err = a.dependencies.Statter.GetStatsForPID(int32(processID), offset)
+	}
+
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		errorMessage := fmt.Sprintf("unable to fetch stats for processID '%v': %v", int32(processID), err)
@@ -126,12 +251,14 @@ func (a *API) getProcess(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Start process watch
-// @Description Start process watch for a specific PID
+// @Description Start process watch for a specific PID; the (optional) body configures the watch
 // @Tags pid
+// @Accept json
 // @Produce json
 // @Param pid path string true "Process ID (int)"
+// @Param config body stat.WatchConfig false "Watch configuration (retention policy, sampling 'interval', 'tree', 'smaps' and 'threads' toggles)"
 // @Success 200 {object} api.StatusResponse "Watch has been started for pid"
-// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid watch config"
 // @Failure 409 {object} api.StatusResponse "PID is already being watched"
 // @Failure 500 {object} api.StatusResponse "Unexpected server error"
 // @Router /api/process/{pid} [post]
@@ -148,7 +275,28 @@ func (a *API) startProcessWatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.dependencies.Statter.StartWatchProcess(int32(processID)); err != nil {
+	config := stat.WatchConfig{}
+
+	// Body is optional
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil && err != io.EOF {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to decode watch config: %v", err),
+		})
+
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("invalid watch config: %v", err),
+		})
+
+		return
+	}
+
+	if err := a.dependencies.Statter.StartWatchProcess(int32(processID), config); err != nil {
 		statusCode := http.StatusInternalServerError
 		errorMessage := fmt.Sprintf("unable to start watch for pid '%v': %v", processID, err)
 
@@ -170,6 +318,73 @@ func (a *API) startProcessWatch(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// @Summary Update process watch
+// @Description Change the sampling interval of a running watch; already collected metrics are kept
+// @Tags pid
+// @Accept json
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Param config body api.UpdateWatchRequest true "New watch settings"
+// @Success 200 {object} api.StatusResponse "Watch has been updated"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid settings"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid} [put]
+func (a *API) updateProcessWatch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	request := UpdateWatchRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to decode request: %v", err),
+		})
+
+		return
+	}
+
+	if err := (stat.WatchConfig{Interval: request.Interval}).Validate(); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("invalid settings: %v", err),
+		})
+
+		return
+	}
+
+	if err := a.dependencies.Statter.SetWatchInterval(int32(processID), time.Duration(request.Interval)); err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to update watch for pid '%v': %v", processID, err)
+
+		if err == stat.NotWatchedErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' is not actively watched", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+		return
+	}
+
+	render.JSON(w, http.StatusOK, StatusResponse{
+		Status:  "ok",
+		Message: fmt.Sprintf("watch updated for pid '%v'", processID),
+	})
+}
+
 // @Summary Stop process watch
 // @Description Stop process watch for a specific PID
 // @Tags pid
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get all finished watches
+// @Description Get every watch whose process has exited (without metrics); finished watches are kept until deleted via
+// @Description DELETE /api/process/{pid}/history
+// @Tags pid
+// @Produce json
+// @Success 200 {array} stat.WatchHistory "Contains zero or more finished watches"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/history [get]
+func (a *API) getFinishedWatches(w http.ResponseWriter, r *http.Request) {
+	finished, err := a.dependencies.Statter.GetFinishedWatches()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, finished)
+}
+
+// @Summary Get the history of a finished watch
+// @Description Get the metrics of a watch whose process has exited along with when and how it exited (exit code or
+// @Description signal if the process was still a zombie when its exit was noticed) and the peak value of every metric.
+// @Description Offset works the same as for /api/process/{pid}.
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Param offset query int false "Fetch metrics at offset"
+// @Success 200 {object} stat.WatchHistory "Finished watch"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int) or invalid offset"
+// @Failure 404 {object} api.StatusResponse "PID has no finished watch"
+// @Failure 416 {object} api.StatusResponse "Invalid offset (too high)"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/history [get]
+func (a *API) getProcessHistory(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	var offset int
+
+	if offsetQueryParam := r.URL.Query().Get("offset"); offsetQueryParam != "" {
+		offset, err = strconv.Atoi(offsetQueryParam)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, StatusResponse{
+				Status:  "error",
+				Message: "offset must be an integer",
+			})
+
+			return
+		}
+	}
+
+	history, err := a.dependencies.Statter.GetHistoryForPID(int32(processID), offset)
+	if err != nil {
+		renderHistoryErr(w, int32(processID), err)
+		return
+	}
+
+	render.JSON(w, http.StatusOK, history)
+}
+
+// @Summary Delete the history of a finished watch
+// @Description Delete a finished watch along with its (stored) metrics
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Success 200 {object} api.StatusResponse "History has been deleted"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int)"
+// @Failure 404 {object} api.StatusResponse "PID has no finished watch"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/history [delete]
+func (a *API) deleteProcessHistory(w http.ResponseWriter, r *http.Request) {
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	if err := a.dependencies.Statter.DeleteHistoryForPID(int32(processID)); err != nil {
+		renderHistoryErr(w, int32(processID), err)
+		return
+	}
+
+	render.JSON(w, http.StatusOK, StatusResponse{
+		Status:  "ok",
+		Message: fmt.Sprintf("history for pid '%v' deleted", processID),
+	})
+}
+
+func renderHistoryErr(w http.ResponseWriter, pid int32, err error) {
+	statusCode := http.StatusInternalServerError
+	errorMessage := err.Error()
+
+	switch err {
+	case stat.NoHistoryErr:
+		statusCode = http.StatusNotFound
+		errorMessage = fmt.Sprintf("pid '%v' has no finished watch", pid)
+	case stat.InvalidOffsetErr:
+		statusCode = http.StatusRequestedRangeNotSatisfiable
+		errorMessage = "provided offset is invalid"
+	}
+
+	render.JSON(w, statusCode, StatusResponse{
+		Status:  "error",
+		Message: errorMessage,
+	})
+}
@@ -0,0 +1,492 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/fleet"
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	// Max size of a single agent push (the full process list + new samples)
+	MaxPushSize = 32 << 20
+)
+
+// @Summary Push samples (agent)
+// @Description Used by 'pidstat agent' to push its process list and new samples of every watch; the response contains
+// @Description commands (start/update/stop watch) queued for the agent. Only available in server mode.
+// @Tags hosts
+// @Accept json
+// @Produce json
+// @Param push body fleet.Push true "Process list + new samples"
+// @Success 200 {object} fleet.PushResponse "Queued commands"
+// @Failure 400 {object} api.StatusResponse "Invalid push (incl. invalid host name or a push that is too large)"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/agent/push [post]
+func (a *API) pushAgent(w http.ResponseWriter, r *http.Request) {
+	push := fleet.Push{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxPushSize)
+
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to decode push: %v", err),
+		})
+
+		return
+	}
+
+	response, err := a.dependencies.Fleet.Push(push)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := err.Error()
+
+		if err == fleet.InvalidHostErr {
+			statusCode = http.StatusBadRequest
+			errorMessage = fmt.Sprintf("invalid host name '%v'", push.Host)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, response)
+}
+
+// @Summary Get all hosts
+// @Description Get every host that has pushed to this server (server mode only)
+// @Tags hosts
+// @Produce json
+// @Success 200 {array} fleet.HostInfo "Contains zero or more hosts"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts [get]
+func (a *API) getHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := a.dependencies.Fleet.GetHosts()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, hosts)
+}
+
+// @Summary Get processes of a host
+// @Description Get the process list last pushed by a host (server mode only); supports the same filtering, sorting
+// @Description and pagination as /api/process
+// @Tags hosts
+// @Produce json
+// @Param host path string true "Host name"
+// @Param name query string false "Only include processes whose name contains this (case-insensitive)"
+// @Param cmdline query string false "Only include processes whose cmd line contains this (case-insensitive)"
+// @Param regex query bool false "Treat 'name' and 'cmdline' as regular expressions"
+// @Param user query string false "Only include processes owned by this user"
+// @Param watched query bool false "Only include watched processes"
+// @Param sort query string false "Sort by pid (default), name, cpu or rss; prefix with '-' for descending order"
+// @Param limit query int false "Return at most this many processes"
+// @Param cursor query string false "Cursor for the next page (from X-Next-Cursor)"
+// @Success 200 {array} stat.ProcInfo "Contains zero or more processes"
+// @Failure 400 {object} api.StatusResponse "Invalid filter, sort, limit or cursor"
+// @Failure 404 {object} api.StatusResponse "Host does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process [get]
+func (a *API) getHostProcesses(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+
+	filter, err := parseProcessFilter(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	processes, err := a.dependencies.Fleet.GetProcesses(host)
+	if err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	page, err := filter.Apply(processes)
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	renderProcessPage(w, page)
+}
+
+// @Summary Get the process tree of a host
+// @Description Get the process list last pushed by a host arranged by parent PID (server mode only); works the same as
+// @Description /api/process/tree
+// @Tags hosts
+// @Produce json
+// @Param host path string true "Host name"
+// @Param name query string false "Only include processes whose name contains this (case-insensitive)"
+// @Param cmdline query string false "Only include processes whose cmd line contains this (case-insensitive)"
+// @Param regex query bool false "Treat 'name' and 'cmdline' as regular expressions"
+// @Param user query string false "Only include processes owned by this user"
+// @Param watched query bool false "Only include watched processes"
+// @Success 200 {array} stat.ProcessNode "Contains zero or more top level processes"
+// @Failure 400 {object} api.StatusResponse "Invalid filter"
+// @Failure 404 {object} api.StatusResponse "Host does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/tree [get]
+func (a *API) getHostProcessTree(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+
+	filter, err := parseProcessFilter(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	processes, err := a.dependencies.Fleet.GetProcesses(host)
+	if err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	tree, err := filter.ApplyTree(stat.BuildProcessTree(processes))
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, tree)
+}
+
+// @Summary Get metrics for a process on a host
+// @Description Get pushed metrics for a (current or past) watch on a host; offset, from, to, step and limit work the
+// @Description same as for /api/process/{pid} (server mode only)
+// @Tags hosts
+// @Produce json
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Param offset query int false "Fetch metrics at offset"
+// @Param from query string false "Only include samples at or after this time (RFC3339 or unix seconds)"
+// @Param to query string false "Only include samples at or before this time (RFC3339 or unix seconds)"
+// @Param step query string false "Resample to one averaged sample per step (ie. '1m' or seconds)"
+// @Param limit query int false "Return at most this many (most recent) samples"
+// @Success 200 {object} stat.ProcInfo "Process metrics"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int), invalid offset or invalid time range query"
+// @Failure 404 {object} api.StatusResponse "Host does not exist or PID has not been watched"
+// @Failure 416 {object} api.StatusResponse "Invalid offset (too high)"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid} [get]
+func (a *API) getHostProcess(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	query, isQuery, err := parseQuery(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	offsetQueryParam := r.URL.Query().Get("offset")
+
+	if isQuery && offsetQueryParam != "" {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: "offset cannot be combined with from, to, step or limit",
+		})
+
+		return
+	}
+
+	var offset int
+
+	if offsetQueryParam != "" {
+		offset, err = strconv.Atoi(offsetQueryParam)
+		if err != nil {
+			render.JSON(w, http.StatusBadRequest, StatusResponse{
+				Status:  "error",
+				Message: "offset must be an integer",
+			})
+
+			return
+		}
+	}
+
+	var procInfo stat.ProcInfo
+
+	if isQuery {
+		procInfo, err = a.dependencies.Fleet.QueryStatsForPID(host, int32(processID), query)
+	} else {
+		procInfo, err = a.dependencies.Fleet.GetStatsForPID(host, int32(processID), offset)
+	}
+
+	if err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	render.JSON(w, http.StatusOK, procInfo)
+}
+
+// @Summary Start process watch on a host
+// @Description Queue a watch start for a process on a host; it is executed once the agent pushes next (server mode only)
+// @Tags hosts
+// @Accept json
+// @Produce json
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Param config body stat.WatchConfig false "Watch configuration"
+// @Success 202 {object} api.StatusResponse "Watch start has been queued"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid watch config"
+// @Failure 404 {object} api.StatusResponse "Host does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid} [post]
+func (a *API) startHostProcessWatch(w http.ResponseWriter, r *http.Request) {
+	a.queueHostCommand(w, r, fleet.ActionStart)
+}
+
+// @Summary Update process watch on a host
+// @Description Queue a sampling interval change for a watch on a host (server mode only)
+// @Tags hosts
+// @Accept json
+// @Produce json
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Param config body api.UpdateWatchRequest true "New watch settings"
+// @Success 202 {object} api.StatusResponse "Watch update has been queued"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid settings"
+// @Failure 404 {object} api.StatusResponse "Host does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid} [put]
+func (a *API) updateHostProcessWatch(w http.ResponseWriter, r *http.Request) {
+	a.queueHostCommand(w, r, fleet.ActionUpdate)
+}
+
+// @Summary Stop process watch on a host
+// @Description Queue a watch stop for a process on a host (server mode only)
+// @Tags hosts
+// @Produce json
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Success 202 {object} api.StatusResponse "Watch stop has been queued"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 404 {object} api.StatusResponse "Host does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid} [delete]
+func (a *API) stopHostProcessWatch(w http.ResponseWriter, r *http.Request) {
+	a.queueHostCommand(w, r, fleet.ActionStop)
+}
+
+func (a *API) queueHostCommand(w http.ResponseWriter, r *http.Request, action string) {
+	host := chi.URLParam(r, "host")
+
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	command := fleet.Command{
+		Action: action,
+		PID:    int32(processID),
+	}
+
+	// UpdateWatchRequest only has 'interval' which lines up with WatchConfig
+	if action != fleet.ActionStop {
+		if err := json.NewDecoder(r.Body).Decode(&command.Config); err != nil && err != io.EOF {
+			render.JSON(w, http.StatusBadRequest, StatusResponse{
+				Status:  "error",
+				Message: fmt.Sprintf("unable to decode watch config: %v", err),
+			})
+
+			return
+		}
+
+		if err := command.Config.Validate(); err != nil {
+			render.JSON(w, http.StatusBadRequest, StatusResponse{
+				Status:  "error",
+				Message: fmt.Sprintf("invalid watch config: %v", err),
+			})
+
+			return
+		}
+	}
+
+	if err := a.dependencies.Fleet.QueueCommand(host, command); err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	render.JSON(w, http.StatusAccepted, StatusResponse{
+		Status:  "ok",
+		Message: fmt.Sprintf("%v watch for pid '%v' queued for host '%v'", action, processID, host),
+	})
+}
+
+// @Summary Get summary statistics for a process on a host
+// @Description Works the same as /api/process/{pid}/summary for the pushed samples of a (current or past) watch on a
+// @Description host (server mode only)
+// @Tags hosts
+// @Produce json
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Param from query string false "Only include samples at or after this time (RFC3339 or unix seconds)"
+// @Param to query string false "Only include samples at or before this time (RFC3339 or unix seconds)"
+// @Success 200 {object} stat.Summary "Summary statistics"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid time window"
+// @Failure 404 {object} api.StatusResponse "Host does not exist or PID has not been watched"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid}/summary [get]
+func (a *API) getHostProcessSummary(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	summary, err := a.dependencies.Fleet.GetSummaryForPID(host, int32(processID), from, to)
+	if err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	render.JSON(w, http.StatusOK, summary)
+}
+
+// @Summary Get a report for a process on a host
+// @Description Works the same as /api/process/{pid}/report for the pushed samples of a (current or past) watch on a
+// @Description host (server mode only)
+// @Tags hosts
+// @Produce json
+// @Produce plain
+// @Produce html
+// @Param host path string true "Host name"
+// @Param pid path string true "Process ID (int)"
+// @Param format query string false "Report format: json (default), csv or html"
+// @Success 200 {object} report.Report "Report"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid format"
+// @Failure 404 {object} api.StatusResponse "Host does not exist or PID has not been watched"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/hosts/{host}/process/{pid}/report [get]
+func (a *API) getHostProcessReport(w http.ResponseWriter, r *http.Request) {
+	host := chi.URLParam(r, "host")
+
+	processID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	format, err := parseReportFormat(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	procInfo, err := a.dependencies.Fleet.GetStatsForPID(host, int32(processID), 0)
+	if err != nil {
+		renderFleetErr(w, host, err)
+		return
+	}
+
+	renderReport(w, procInfo, format)
+}
+
+// Per-process endpoints that need the agent's procfs or its live samples
+// (stream, ws, fds, threads) or exit info (agents do not push it; history) are
+// not available per host; answered with a 501 so that clients can tell them
+// apart from a typo
+func (a *API) hostRouteNotSupported(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, http.StatusNotImplemented, StatusResponse{
+		Status:  "error",
+		Message: fmt.Sprintf("'%v' is not available for hosts; only process list, tree, metrics, summary and report are", r.URL.Path),
+	})
+}
+
+func renderFleetErr(w http.ResponseWriter, host string, err error) {
+	statusCode := http.StatusInternalServerError
+	errorMessage := err.Error()
+
+	switch err {
+	case fleet.HostNotFoundErr:
+		statusCode = http.StatusNotFound
+		errorMessage = fmt.Sprintf("host '%v' does not exist", host)
+	case stat.NotWatchedErr:
+		statusCode = http.StatusNotFound
+		errorMessage = "pid has not been watched on this host"
+	case stat.InvalidOffsetErr:
+		statusCode = http.StatusRequestedRangeNotSatisfiable
+		errorMessage = "provided offset is invalid"
+	}
+
+	render.JSON(w, statusCode, StatusResponse{
+		Status:  "error",
+		Message: errorMessage,
+	})
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	gauge   = "gauge"
+	counter = "counter"
+)
+
+// exportedMetric describes a single per-process Prometheus metric
+type exportedMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(m stat.ProcInfoMetrics) float64
+
+	// Not always available (ie. smaps, unlimited fds); zero values are left out
+	optional bool
+}
+
+var exportedMetrics = []exportedMetric{
+	{
+		name:  "pidstat_process_cpu_percent",
+		help:  "CPU usage of the process in percent (100 == one full core)",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return m.CPU },
+	},
+	{
+		name:  "pidstat_process_resident_memory_bytes",
+		help:  "Resident set size of the process in bytes",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.RSS) },
+	},
+	{
+		name:  "pidstat_process_virtual_memory_bytes",
+		help:  "Virtual memory size of the process in bytes",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.VMS) },
+	},
+	{
+		name:  "pidstat_process_swap_bytes",
+		help:  "Swapped out memory of the process in bytes",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.Swap) },
+	},
+	{
+		name:  "pidstat_process_threads",
+		help:  "Number of threads in the process",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.Threads) },
+	},
+	{
+		name:  "pidstat_process_read_bytes_total",
+		help:  "Bytes read from storage by the process",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.ReadBytes) },
+	},
+	{
+		name:  "pidstat_process_written_bytes_total",
+		help:  "Bytes written to storage by the process",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.WriteBytes) },
+	},
+	{
+		name:  "pidstat_process_read_syscalls_total",
+		help:  "Read syscalls made by the process",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.ReadCount) },
+	},
+	{
+		name:  "pidstat_process_write_syscalls_total",
+		help:  "Write syscalls made by the process",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.WriteCount) },
+	},
+	{
+		name:  "pidstat_process_read_bytes_per_second",
+		help:  "Storage read throughput of the process over the last sample interval",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return m.ReadBytesPerSec },
+	},
+	{
+		name:  "pidstat_process_write_bytes_per_second",
+		help:  "Storage write throughput of the process over the last sample interval",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return m.WriteBytesPerSec },
+	},
+	{
+		name:  "pidstat_process_voluntary_context_switches_total",
+		help:  "Context switches because the process gave up the CPU (ie. blocked on I/O)",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.VoluntaryCtxSwitches) },
+	},
+	{
+		name:  "pidstat_process_involuntary_context_switches_total",
+		help:  "Context switches because the process was preempted",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.InvoluntaryCtxSwitches) },
+	},
+	{
+		name:  "pidstat_process_minor_page_faults_total",
+		help:  "Page faults of the process that did not require loading a page from disk",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.MinorFaults) },
+	},
+	{
+		name:  "pidstat_process_major_page_faults_total",
+		help:  "Page faults of the process that required loading a page from disk",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.MajorFaults) },
+	},
+	{
+		name:  "pidstat_process_run_queue_wait_seconds_total",
+		help:  "Time the process spent runnable but waiting for a CPU",
+		kind:  counter,
+		value: func(m stat.ProcInfoMetrics) float64 { return m.RunQueueWait },
+	},
+	{
+		name:  "pidstat_process_open_fds",
+		help:  "Number of open file descriptors of the process",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.FDs) },
+	},
+	{
+		name:  "pidstat_process_open_file_fds",
+		help:  "Number of open file descriptors of the process that refer to files",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.FileFDs) },
+	},
+	{
+		name:  "pidstat_process_open_socket_fds",
+		help:  "Number of open file descriptors of the process that refer to sockets",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.SocketFDs) },
+	},
+	{
+		name:  "pidstat_process_open_pipe_fds",
+		help:  "Number of open file descriptors of the process that refer to pipes",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.PipeFDs) },
+	},
+	{
+		name:  "pidstat_process_open_anon_inode_fds",
+		help:  "Number of open file descriptors of the process that refer to anonymous inodes (ie. eventfd, epoll)",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.AnonInodeFDs) },
+	},
+	{
+		name:     "pidstat_process_max_fds",
+		help:     "Soft limit on the number of open file descriptors of the process (RLIMIT_NOFILE)",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.FDSoftLimit) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_max_fds_hard",
+		help:     "Hard limit on the number of open file descriptors of the process (RLIMIT_NOFILE)",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.FDHardLimit) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_proportional_memory_bytes",
+		help:     "Proportional set size (PSS) of the process in bytes; only for watches with smaps enabled",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.PSS) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_unique_memory_bytes",
+		help:     "Unique set size (USS) of the process in bytes; only for watches with smaps enabled",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.USS) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_shared_clean_memory_bytes",
+		help:     "Clean pages the process shares with others in bytes; only for watches with smaps enabled",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.SharedClean) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_shared_dirty_memory_bytes",
+		help:     "Dirty pages the process shares with others in bytes; only for watches with smaps enabled",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.SharedDirty) },
+		optional: true,
+	},
+	{
+		name:     "pidstat_process_anonymous_memory_bytes",
+		help:     "Anonymous memory of the process in bytes; only for watches with smaps enabled",
+		kind:     gauge,
+		value:    func(m stat.ProcInfoMetrics) float64 { return float64(m.Anonymous) },
+		optional: true,
+	},
+	{
+		name:  "pidstat_process_last_sample_timestamp_seconds",
+		help:  "Unix time at which the most recent sample was collected",
+		kind:  gauge,
+		value: func(m stat.ProcInfoMetrics) float64 { return float64(m.Timestamp.UnixNano()) / 1e9 },
+	},
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// @Summary Prometheus metrics for watched processes
+// @Description Exports the most recent sample of every watched process in the Prometheus text format;
+// @Description every series is labelled by pid, name and cmdline
+// @Tags metrics
+// @Produce plain
+// @Success 200 {string} string "Prometheus metrics"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /metrics [get]
+func (a *API) getMetrics(w http.ResponseWriter, r *http.Request) {
+	procInfos, err := a.dependencies.Statter.GetWatchedProcesses()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to fetch watched processes: %v", err),
+		})
+
+		return
+	}
+
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("# HELP pidstat_watched_processes Number of actively watched processes\n")
+	buf.WriteString("# TYPE pidstat_watched_processes gauge\n")
+	fmt.Fprintf(buf, "pidstat_watched_processes %d\n", len(procInfos))
+
+	for _, metric := range exportedMetrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", metric.name, metric.kind)
+
+		for _, procInfo := range procInfos {
+			// No sample collected yet
+			if len(procInfo.Metrics) == 0 {
+				continue
+			}
+
+			value := metric.value(procInfo.Metrics[0])
+
+			if metric.optional && value == 0 {
+				continue
+			}
+
+			fmt.Fprintf(buf, "%s{%s} %s\n", metric.name, labels(procInfo),
+				strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		sugar.Errorf("unable to write metrics response: %v", err)
+	}
+}
+
+func labels(procInfo stat.ProcInfo) string {
+	return fmt.Sprintf(`pid="%d",name="%s",cmdline="%s"`, procInfo.PID,
+		labelValueEscaper.Replace(procInfo.Name), labelValueEscaper.Replace(procInfo.CmdLine))
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// Parse time range query params; false if none of them are set
+func parseQuery(r *http.Request) (stat.Query, bool, error) {
+	values := r.URL.Query()
+
+	if values.Get("from") == "" && values.Get("to") == "" && values.Get("step") == "" && values.Get("limit") == "" {
+		return stat.Query{}, false, nil
+	}
+
+	var (
+		query stat.Query
+		err   error
+	)
+
+	if query.From, err = parseTimeParam(r, "from"); err != nil {
+		return stat.Query{}, true, err
+	}
+
+	if query.To, err = parseTimeParam(r, "to"); err != nil {
+		return stat.Query{}, true, err
+	}
+
+	if step := values.Get("step"); step != "" {
+		if seconds, err := strconv.ParseFloat(step, 64); err == nil {
+			query.Step = time.Duration(seconds * float64(time.Second))
+		} else if query.Step, err = time.ParseDuration(step); err != nil {
+			return stat.Query{}, true, fmt.Errorf("invalid 'step' (expected duration or seconds): %v", err)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return stat.Query{}, true, fmt.Errorf("invalid 'limit': %v", err)
+		}
+	}
+
+	if err := query.Validate(); err != nil {
+		return stat.Query{}, true, err
+	}
+
+	return query, true, nil
+}
+
+// Parse an (optional) RFC3339 or unix seconds query param; zero time if not set
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%v' (expected RFC3339 or unix seconds): %v", name, err)
+	}
+
+	return t, nil
+}
+
+// Parse the optional 'from' + 'to' query params
+func parseTimeWindow(r *http.Request) (time.Time, time.Time, error) {
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("'to' must not be before 'from'")
+	}
+
+	return from, to, nil
+}
+
+// Parse process list filter query params
+func parseProcessFilter(r *http.Request) (stat.ProcessFilter, error) {
+	values := r.URL.Query()
+
+	filter := stat.ProcessFilter{
+		Name:    values.Get("name"),
+		CmdLine: values.Get("cmdline"),
+		User:    values.Get("user"),
+		Sort:    values.Get("sort"),
+		Cursor:  values.Get("cursor"),
+	}
+
+	var err error
+
+	if filter.Regex, err = parseBoolParam(r, "regex"); err != nil {
+		return stat.ProcessFilter{}, err
+	}
+
+	if filter.WatchedOnly, err = parseBoolParam(r, "watched"); err != nil {
+		return stat.ProcessFilter{}, err
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return stat.ProcessFilter{}, fmt.Errorf("invalid 'limit': %v", err)
+		}
+	}
+
+	if err := filter.Validate(); err != nil {
+		return stat.ProcessFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// Parse an (optional) bool query param; false if not set
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid '%v' (expected bool): %v", name, err)
+	}
+
+	return b, nil
+}
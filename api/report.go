@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/report"
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get a report for a watched process
+// @Description Renders all retained samples of a (current or stored) watch plus summary statistics. 'csv' contains one
+// @Description row per sample; 'html' is a self-contained page with embedded charts (suitable for attaching to tickets).
+// @Tags pid
+// @Produce json
+// @Produce plain
+// @Produce html
+// @Param pid path string true "Process ID (int)"
+// @Param format query string false "Report format: json (default), csv or html"
+// @Success 200 {object} report.Report "Report"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid format"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched (and has no stored history)"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/report [get]
+func (a *API) getProcessReport(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	format, err := parseReportFormat(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	procInfo, err := a.dependencies.Statter.GetStatsForPID(int32(processID), 0)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to fetch stats for pid '%v': %v", processID, err)
+
+		if err == stat.NotWatchedErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' is not actively watched", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return
+	}
+
+	renderReport(w, procInfo, format)
+}
+
+// Parse the (optional) 'format' query param
+func parseReportFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = report.FormatJSON
+	}
+
+	if !report.ValidFormat(format) {
+		return "", fmt.Errorf("invalid format '%v' (expected one of %v)", format, strings.Join(report.Formats, ", "))
+	}
+
+	return format, nil
+}
+
+func renderReport(w http.ResponseWriter, procInfo stat.ProcInfo, format string) {
+	rep := report.New(procInfo)
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", rep.Filename(format)))
+	w.WriteHeader(http.StatusOK)
+
+	if err := rep.Render(w, format); err != nil {
+		sugar.Errorf("unable to write report for pid '%v': %v", procInfo.PID, err)
+	}
+}
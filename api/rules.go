@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get all watch rules
+// @Description Get all watch rules; every process matching a rule is watched automatically
+// @Tags rules
+// @Produce json
+// @Success 200 {array} stat.WatchRule "Contains zero or more rules"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/rules [get]
+func (a *API) getRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := a.dependencies.Statter.GetRules()
+	if err != nil {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, rules)
+}
+
+// @Summary Add a watch rule
+// @Description Add a rule that automatically watches every process matching it (now and in the future, including
+// @Description restarted processes). 'name' and 'exe' are globs, 'cmd_line' is a regular expression and 'user' is
+// @Description matched exactly; all set matchers must match. A watch started by a rule that is stopped by hand is
+// @Description not re-attached for the same pid.
+// @Tags rules
+// @Accept json
+// @Produce json
+// @Param rule body stat.WatchRule true "Rule (id is generated if not set)"
+// @Success 200 {object} stat.WatchRule "Created rule"
+// @Failure 400 {object} api.StatusResponse "Invalid rule"
+// @Failure 409 {object} api.StatusResponse "Rule with this ID already exists"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/rules [post]
+func (a *API) addRule(w http.ResponseWriter, r *http.Request) {
+	rule := stat.WatchRule{}
+
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to decode rule: %v", err),
+		})
+
+		return
+	}
+
+	if err := rule.Validate(); err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("invalid rule: %v", err),
+		})
+
+		return
+	}
+
+	created, err := a.dependencies.Statter.AddRule(rule)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to add rule: %v", err)
+
+		if err == stat.RuleExistsErr {
+			statusCode = http.StatusConflict
+			errorMessage = fmt.Sprintf("rule '%v' already exists", rule.ID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+		return
+	}
+
+	render.JSON(w, http.StatusOK, created)
+}
+
+// @Summary Delete a watch rule
+// @Description Delete a watch rule by ID; watches that were already started by the rule keep running
+// @Tags rules
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} api.StatusResponse "Rule has been deleted"
+// @Failure 404 {object} api.StatusResponse "Rule does not exist"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/rules/{id} [delete]
+func (a *API) deleteRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := a.dependencies.Statter.DeleteRule(id); err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to delete rule '%v': %v", id, err)
+
+		if err == stat.RuleNotFoundErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("rule '%v' does not exist", id)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+		return
+	}
+
+	render.JSON(w, http.StatusOK, StatusResponse{
+		Status:  "ok",
+		Message: fmt.Sprintf("rule '%v' deleted", id),
+	})
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"golang.org/x/net/websocket"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	// How often an idle SSE stream gets a keepalive so proxies do not time it out
+	StreamKeepaliveInterval = 15 * time.Second
+)
+
+// StreamMessage is emitted over the websocket stream
+type StreamMessage struct {
+	// One of "metrics", "dropped" or "end"
+	Type string `json:"type"`
+
+	// Set for "metrics" messages
+	Metrics *stat.ProcInfoMetrics `json:"metrics,omitempty"`
+
+	// Set for "dropped" messages; total number of samples dropped so far
+	// because the client was not keeping up
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// Parse pid + subscribe to it; writes an error response and returns nil if that fails
+func (a *API) subscribe(w http.ResponseWriter, r *http.Request) *stat.Subscription {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return nil
+	}
+
+	sub, err := a.dependencies.Statter.Subscribe(int32(processID))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to subscribe to pid '%v': %v", processID, err)
+
+		if err == stat.NotWatchedErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' is not actively watched", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return nil
+	}
+
+	return sub
+}
+
+// @Summary Stream metrics for a watched process (SSE)
+// @Description Streams every new sample for a watched process as Server-Sent Events (text/event-stream). Each sample is sent as a
+// @Description 'metrics' event; a 'dropped' event (containing the total number of dropped samples) is sent if the
+// @Description client is not keeping up and an 'end' event is sent once the watch ends.
+// @Tags pid
+// @Produce plain
+// @Param pid path string true "Process ID (int)"
+// @Success 200 {object} stat.ProcInfoMetrics "Stream of samples"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/stream [get]
+func (a *API) streamProcessSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		render.JSON(w, http.StatusInternalServerError, StatusResponse{
+			Status:  "error",
+			Message: "streaming is not supported by this connection",
+		})
+
+		return
+	}
+
+	sub := a.subscribe(w, r)
+	if sub == nil {
+		return
+	}
+
+	defer a.dependencies.Statter.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(StreamKeepaliveInterval)
+	defer keepalive.Stop()
+
+	var dropped uint64
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case m, ok := <-sub.C:
+			if !ok {
+				fmt.Fprint(w, "event: end\ndata: {}\n\n")
+				flusher.Flush()
+
+				return
+			}
+
+			if sub.Dropped() != dropped {
+				dropped = sub.Dropped()
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped)
+			}
+
+			data, err := json.Marshal(m)
+			if err != nil {
+				sugar.Errorf("unable to marshal sample for pid '%v': %v", sub.PID, err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: metrics\ndata: %s\n\n", data)
+		}
+
+		flusher.Flush()
+	}
+}
+
+// @Summary Stream metrics for a watched process (WebSocket)
+// @Description Streams every new sample for a watched process over a WebSocket as JSON 'api.StreamMessage's
+// @Description ('metrics', 'dropped' and finally 'end' once the watch ends). Anything sent by the client is ignored.
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Success 101 {object} api.StreamMessage "Stream of messages"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 403 {string} string "Origin is not one of the configured CORS origins"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/ws [get]
+func (a *API) streamProcessWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub := a.subscribe(w, r)
+	if sub == nil {
+		return
+	}
+
+	defer a.dependencies.Statter.Unsubscribe(sub)
+
+	websocket.Server{
+		Handshake: a.checkWebSocketOrigin,
+		Handler: func(conn *websocket.Conn) {
+			a.streamToWebSocket(conn, sub)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// Browsers do not apply CORS to WebSockets; once CORS origins are configured,
+// only those origins may connect. Clients that send no Origin (ie. non-browser
+// clients) are not affected. A failed handshake is answered with a 403.
+func (a *API) checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+
+	config.Origin = origin
+
+	if origin == nil || len(a.config.CORSOrigins) == 0 {
+		return nil
+	}
+
+	if !originAllowed(a.config.CORSOrigins, origin.String()) {
+		return fmt.Errorf("origin '%v' is not allowed", origin)
+	}
+
+	return nil
+}
+
+// Same matching as the CORS middleware: case-insensitive, "*" allows any
+// origin and a single "*" within an origin matches anything (ie.
+// "https://*.example.com")
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, o := range allowed {
+		o = strings.ToLower(o)
+
+		if o == "*" || o == origin {
+			return true
+		}
+
+		if i := strings.IndexByte(o, '*'); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (a *API) streamToWebSocket(conn *websocket.Conn, sub *stat.Subscription) {
+	defer conn.Close()
+
+	// We do not expect anything from the client; reading is only used for
+	// noticing that it has gone away
+	gone := make(chan struct{})
+
+	go func() {
+		defer close(gone)
+
+		var discard []byte
+
+		for {
+			if err := websocket.Message.Receive(conn, &discard); err != nil {
+				return
+			}
+		}
+	}()
+
+	var dropped uint64
+
+	for {
+		var messages []StreamMessage
+
+		select {
+		case <-gone:
+			return
+		case m, ok := <-sub.C:
+			if !ok {
+				messages = append(messages, StreamMessage{Type: "end"})
+				break
+			}
+
+			if sub.Dropped() != dropped {
+				dropped = sub.Dropped()
+				messages = append(messages, StreamMessage{Type: "dropped", Dropped: dropped})
+			}
+
+			messages = append(messages, StreamMessage{Type: "metrics", Metrics: &m})
+		}
+
+		for _, message := range messages {
+			if err := websocket.JSON.Send(conn, message); err != nil {
+				sugar.Debugf("websocket client for pid '%v' went away: %v", sub.PID, err)
+				return
+			}
+
+			if message.Type == "end" {
+				return
+			}
+		}
+	}
+}
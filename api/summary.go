@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get summary statistics for a watched process
+// @Description Get min/max/mean/stddev/p50/p90/p99/last for every metric of a (current or stored) watch, computed
+// @Description server-side over an optional time window. Covers all retained raw samples and downsampled buckets
+// @Description (each bucket counts as its number of samples at its average, so stddev and percentiles are approximate).
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Param from query string false "Only include samples at or after this time (RFC3339 or unix seconds)"
+// @Param to query string false "Only include samples at or before this time (RFC3339 or unix seconds)"
+// @Success 200 {object} stat.Summary "Summary statistics"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?) or invalid time window"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched (and has no stored history)"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/summary [get]
+func (a *API) getProcessSummary(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: err.Error(),
+		})
+
+		return
+	}
+
+	summary, err := a.dependencies.Statter.GetSummaryForPID(int32(processID), from, to)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := fmt.Sprintf("unable to summarize stats for pid '%v': %v", processID, err)
+
+		if err == stat.NotWatchedErr {
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' is not actively watched", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, summary)
+}
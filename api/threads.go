@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+// @Summary Get per-thread metrics of a watched process
+// @Description Get every thread of a watch that was started with '"threads": true' along with the retained samples
+// @Description (state, cumulative user/system CPU time and CPU usage since the previous sample) of each thread.
+// @Description Threads that have exited are dropped.
+// @Tags pid
+// @Produce json
+// @Param pid path string true "Process ID (int)"
+// @Success 200 {array} stat.ThreadInfo "Threads, ordered by tid"
+// @Failure 400 {object} api.StatusResponse "Invalid PID (not int?)"
+// @Failure 404 {object} api.StatusResponse "PID is not being watched"
+// @Failure 409 {object} api.StatusResponse "Watch does not collect per-thread metrics"
+// @Failure 500 {object} api.StatusResponse "Unexpected server error"
+// @Router /api/process/{pid}/threads [get]
+func (a *API) getProcessThreads(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	processID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		render.JSON(w, http.StatusBadRequest, StatusResponse{
+			Status:  "error",
+			Message: fmt.Sprintf("unable to convert id to int: %v", err),
+		})
+
+		return
+	}
+
+	threads, err := a.dependencies.Statter.GetThreadsForPID(int32(processID))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorMessage := err.Error()
+
+		switch err {
+		case stat.NotWatchedErr:
+			statusCode = http.StatusNotFound
+			errorMessage = fmt.Sprintf("pid '%v' is not actively watched", processID)
+		case stat.ThreadsNotEnabledErr:
+			statusCode = http.StatusConflict
+			errorMessage = fmt.Sprintf("watch for pid '%v' does not collect per-thread metrics", processID)
+		}
+
+		render.JSON(w, statusCode, StatusResponse{
+			Status:  "error",
+			Message: errorMessage,
+		})
+
+		return
+	}
+
+	render.JSON(w, http.StatusOK, threads)
+}
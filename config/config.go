@@ -0,0 +1,371 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	DefaultListenAddress = ":8787"
+
+	// Every setting can be overridden via PIDSTAT_<SETTING> (ie. PIDSTAT_STAT_INTERVAL);
+	// list settings take a JSON array
+	EnvPrefix = "PIDSTAT_"
+
+	// RoleRead can only query; RoleWrite can also start/stop watches and
+	// manage rules
+	RoleRead  = "read"
+	RoleWrite = "write"
+)
+
+// Config contains all pidstat settings. Example (YAML):
+//
+//	listen_address: ":8787"
+//	data_dir: /var/lib/pidstat
+//	stat_interval: 1s
+//	alert_webhook: https://example.com/hook
+//	tls_self_signed: true
+//	cors_origins: ["https://dashboard.example.com"]
+//	credentials:
+//	  - token: s3cr3t
+//	    role: write
+//	  - username: viewer
+//	    password: hunter2
+//	watches:
+//	  - pid_file: /run/nginx.pid
+//	    tree: true
+//	  - pid: 1
+//	    interval: 10s
+type Config struct {
+	// Address the web server binds to
+	ListenAddress string `json:"listen_address"`
+
+	// Serve HTTPS using these files, or using a generated self-signed cert
+	TLSCert       string `json:"tls_cert"`
+	TLSKey        string `json:"tls_key"`
+	TLSSelfSigned bool   `json:"tls_self_signed"`
+
+	// Origins allowed to make cross-origin requests (empty: any)
+	CORSOrigins []string `json:"cors_origins"`
+
+	// Bearer tokens / basic auth users; empty means no authentication
+	Credentials []Credential `json:"credentials"`
+
+	// Persist watches + metrics to this dir (empty: keep in memory only)
+	DataDir string `json:"data_dir"`
+
+	// Where logs go while the console dashboard is running (empty: discard)
+	LogFile string `json:"log_file"`
+
+	// Use the prod logger (JSON, INFO+) instead of the dev one
+	ProductionLogging bool `json:"production_logging"`
+
+	// Zero means "use the default" (see stat.Settings)
+	CacheProcessListInterval stat.Duration `json:"cache_process_list_interval"`
+	StatInterval             stat.Duration `json:"stat_interval"`
+
+	// Additional alert delivery (alerts are always logged)
+	AlertCommand string `json:"alert_command"`
+	AlertWebhook string `json:"alert_webhook"`
+
+	// Watches started at boot (and on reload, if added or changed)
+	Watches []Watch `json:"watches"`
+
+	// Agent mode: push samples to this server (ie. https://pidstat.example.com:8787)
+	AgentServer string `json:"agent_server"`
+
+	// Name this host reports as (default: hostname)
+	AgentHost string `json:"agent_host"`
+
+	// Bearer token for the server (needs the write role)
+	AgentToken string `json:"agent_token"`
+
+	// How often samples are pushed (default: 5s)
+	AgentPushInterval stat.Duration `json:"agent_push_interval"`
+
+	// Skip TLS certificate verification (ie. for self-signed servers)
+	AgentInsecure bool `json:"agent_insecure"`
+}
+
+// Watch is a watch that gets started at boot; the process is identified
+// either by pid or by a pid file
+type Watch struct {
+	PID     int32  `json:"pid,omitempty"`
+	PIDFile string `json:"pid_file,omitempty"`
+
+	stat.WatchConfig
+}
+
+// Credential is either a bearer token or a basic auth username + password
+type Credential struct {
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// RoleRead (default) or RoleWrite
+	Role string `json:"role,omitempty"`
+}
+
+// Default returns the config used when nothing is configured
+func Default() *Config {
+	return &Config{
+		ListenAddress: DefaultListenAddress,
+		CORSOrigins:   make([]string, 0),
+		Credentials:   make([]Credential, 0),
+		Watches:       make([]Watch, 0),
+	}
+}
+
+// Load reads the config from 'path' (YAML, TOML or JSON, picked by file
+// extension; empty path means defaults only) and applies PIDSTAT_* env
+// overrides on top of it
+func Load(path string) (*Config, error) {
+	values := make(map[string]interface{}, 0)
+
+	if path != "" {
+		var err error
+
+		values, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(values); err != nil {
+		return nil, err
+	}
+
+	// Everything is funneled through JSON so the JSON (un)marshalers of the
+	// stat types (ie. durations) are used for every format
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert config: %v", err)
+	}
+
+	cfg := Default()
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate the config
+func (c *Config) Validate() error {
+	if err := c.Settings().Validate(); err != nil {
+		return err
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("'tls_cert' and 'tls_key' must be set together")
+	}
+
+	if c.TLSCert != "" && c.TLSSelfSigned {
+		return errors.New("'tls_self_signed' cannot be combined with 'tls_cert'")
+	}
+
+	for i := range c.Credentials {
+		if err := c.Credentials[i].Validate(); err != nil {
+			return fmt.Errorf("credential #%v: %v", i+1, err)
+		}
+	}
+
+	for i, w := range c.Watches {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("watch #%v: %v", i+1, err)
+		}
+	}
+
+	if c.AgentPushInterval < 0 {
+		return errors.New("'agent_push_interval' cannot be negative")
+	}
+
+	return nil
+}
+
+// TLS returns true if the web server should serve HTTPS
+func (c *Config) TLS() bool {
+	return c.TLSCert != "" || c.TLSSelfSigned
+}
+
+// Validate the credential + fill in defaults
+func (c *Credential) Validate() error {
+	if (c.Token == "") == (c.Username == "") {
+		return errors.New("exactly one of 'token' or 'username' must be set")
+	}
+
+	if c.Username != "" && c.Password == "" {
+		return errors.New("'password' is required for 'username'")
+	}
+
+	if c.Role == "" {
+		c.Role = RoleRead
+	}
+
+	if c.Role != RoleRead && c.Role != RoleWrite {
+		return fmt.Errorf("invalid role '%v' (expected %v or %v)", c.Role, RoleRead, RoleWrite)
+	}
+
+	return nil
+}
+
+// Settings returns the stat settings (incl. alert notifiers)
+func (c *Config) Settings() stat.Settings {
+	notifiers := make([]stat.Notifier, 0)
+
+	if c.AlertCommand != "" {
+		notifiers = append(notifiers, stat.NewCommandNotifier(c.AlertCommand))
+	}
+
+	if c.AlertWebhook != "" {
+		notifiers = append(notifiers, stat.NewWebhookNotifier(c.AlertWebhook))
+	}
+
+	return stat.Settings{
+		CacheProcessListInterval: time.Duration(c.CacheProcessListInterval),
+		StatInterval:             time.Duration(c.StatInterval),
+		Notifiers:                notifiers,
+	}
+}
+
+// Validate the watch
+func (w Watch) Validate() error {
+	if (w.PID == 0) == (w.PIDFile == "") {
+		return fmt.Errorf("exactly one of 'pid' or 'pid_file' must be set")
+	}
+
+	return w.WatchConfig.Validate()
+}
+
+// ResolvePID returns the pid of the watch (reading the pid file if needed)
+func (w Watch) ResolvePID() (int32, error) {
+	if w.PIDFile == "" {
+		return w.PID, nil
+	}
+
+	data, err := ioutil.ReadFile(w.PIDFile)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read pid file: %v", err)
+	}
+
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid in '%v': %v", w.PIDFile, err)
+	}
+
+	return int32(pid), nil
+}
+
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config: %v", err)
+	}
+
+	values := make(map[string]interface{}, 0)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		raw := make(map[interface{}]interface{}, 0)
+
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("unable to parse YAML config: %v", err)
+		}
+
+		values = normalizeYAML(raw).(map[string]interface{})
+	case ".toml":
+		if _, err := toml.Decode(string(data), &values); err != nil {
+			return nil, fmt.Errorf("unable to parse TOML config: %v", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("unable to parse JSON config: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format '%v' (expected .yaml, .yml, .toml or .json)", filepath.Ext(path))
+	}
+
+	return values, nil
+}
+
+// yaml.v2 decodes maps as map[interface{}]interface{} which JSON cannot encode
+func normalizeYAML(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+
+		for k, item := range value {
+			m[fmt.Sprintf("%v", k)] = normalizeYAML(item)
+		}
+
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeYAML(item)
+		}
+	}
+
+	return v
+}
+
+// Override top-level scalar settings with PIDSTAT_<SETTING> env vars
+func applyEnv(values map[string]interface{}) error {
+	configType := reflect.TypeOf(Config{})
+	durationType := reflect.TypeOf(stat.Duration(0))
+
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		env := EnvPrefix + strings.ToUpper(name)
+
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case field.Type == durationType, field.Type.Kind() == reflect.String:
+			values[name] = raw
+		case field.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid value for %v: %v", env, err)
+			}
+
+			values[name] = b
+		default:
+			var v interface{}
+
+			if err := json.Unmarshal([]byte(raw), &v); err != nil {
+				return fmt.Errorf("invalid value for %v (expected JSON): %v", env, err)
+			}
+
+			values[name] = v
+		}
+	}
+
+	return nil
+}
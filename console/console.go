@@ -0,0 +1,559 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+
+	"github.com/dselans/pidstat/deps"
+	"github.com/dselans/pidstat/stat"
+	"github.com/dselans/pidstat/util"
+)
+
+const (
+	headerHeight  = 3
+	detailsHeight = 9
+	sparkHeight   = 4
+)
+
+var (
+	// Orders cycled through with 's'
+	sortOrders = []string{stat.SortPID, "-" + stat.SortCPU, "-" + stat.SortRSS, stat.SortName}
+
+	sugar *zap.SugaredLogger
+)
+
+func init() {
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "console"})
+	if err != nil {
+		panic(fmt.Sprintf("unable to setup logger: %v", err))
+	}
+
+	sugar = logger.Sugar()
+}
+
+type Console struct {
+	dependencies *deps.Dependencies
+	logFile      string
+
+	// Current (possibly filtered) process list + what is selected in it
+	processes []stat.ProcInfo
+	selected  int
+	offset    int
+
+	// Display the process tree instead of a flat list; depths holds the
+	// indentation of every entry in processes
+	treeView bool
+	depths   []int
+
+	// Only display watched processes
+	watchedOnly bool
+
+	// Name filter + whether it is being typed in right now
+	nameFilter    string
+	editingFilter bool
+
+	// Index into sortOrders
+	sortOrder int
+
+	// Last status/error message displayed in the header
+	status string
+
+	header     *ui.Par
+	list       *ui.List
+	details    *ui.Par
+	sparklines *ui.Sparklines
+}
+
+func New(logFile string, d *deps.Dependencies) (*Console, error) {
+	return &Console{
+		dependencies: d,
+		logFile:      logFile,
+	}, nil
+}
+
+// Run takes over the terminal and blocks until the user quits
+func (c *Console) Run() error {
+	// Loggers write to stderr which would trash the dashboard - point stderr
+	// somewhere else for as long as we own the terminal.
+	restore, err := c.redirectStderr()
+	if err != nil {
+		return fmt.Errorf("unable to redirect stderr: %v", err)
+	}
+
+	defer restore()
+
+	if err := ui.Init(); err != nil {
+		return fmt.Errorf("unable to initialize terminal: %v", err)
+	}
+
+	defer ui.Close()
+
+	c.setupWidgets()
+	c.setupHandlers()
+
+	c.refresh()
+	c.layout()
+
+	ui.Loop()
+
+	return nil
+}
+
+func (c *Console) redirectStderr() (func(), error) {
+	target := c.logFile
+	if target == "" {
+		target = os.DevNull
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := unix.Dup(int(os.Stderr.Fd()))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := unix.Dup2(int(f.Fd()), int(os.Stderr.Fd())); err != nil {
+		f.Close()
+		unix.Close(saved)
+		return nil, err
+	}
+
+	return func() {
+		if err := unix.Dup2(saved, int(os.Stderr.Fd())); err != nil {
+			sugar.Errorf("unable to restore stderr: %v", err)
+		}
+
+		unix.Close(saved)
+		f.Close()
+	}, nil
+}
+
+func (c *Console) setupWidgets() {
+	c.header = ui.NewPar("")
+	c.header.BorderLabel = "pidstat"
+	c.header.Height = headerHeight
+
+	c.list = ui.NewList()
+	c.list.BorderLabel = "Processes"
+
+	c.details = ui.NewPar("")
+	c.details.BorderLabel = "Details"
+	c.details.Height = detailsHeight
+
+	cpu := ui.NewSparkline()
+	cpu.Height = sparkHeight
+	cpu.LineColor = ui.ColorGreen
+
+	rss := ui.NewSparkline()
+	rss.Height = sparkHeight
+	rss.LineColor = ui.ColorCyan
+
+	threads := ui.NewSparkline()
+	threads.Height = sparkHeight
+	threads.LineColor = ui.ColorYellow
+
+	c.sparklines = ui.NewSparklines(cpu, rss, threads)
+	c.sparklines.BorderLabel = "Metrics"
+	c.sparklines.Height = 3*(sparkHeight+1) + 2
+
+	ui.Body.AddRows(
+		ui.NewRow(
+			ui.NewCol(12, 0, c.header),
+		),
+		ui.NewRow(
+			ui.NewCol(6, 0, c.list),
+			ui.NewCol(6, 0, c.details, c.sparklines),
+		),
+	)
+}
+
+func (c *Console) setupHandlers() {
+	// termui only calls the most specific handler for a path; handle all keys
+	// in one place so typing a filter does not trigger other bindings
+	ui.Handle("/sys/kbd", func(e ui.Event) {
+		key := e.Data.(ui.EvtKbd).KeyStr
+
+		if c.editingFilter {
+			c.editFilter(key)
+			return
+		}
+
+		switch key {
+		case "q", "C-c":
+			ui.StopLoop()
+		case "<up>", "k":
+			c.move(-1)
+		case "<down>", "j":
+			c.move(1)
+		case "<previous>":
+			c.move(-c.visibleRows())
+		case "<next>":
+			c.move(c.visibleRows())
+		case "<enter>", "<space>":
+			c.toggleWatch()
+		case "w":
+			c.watchedOnly = !c.watchedOnly
+			c.refresh()
+			c.render()
+		case "t":
+			c.treeView = !c.treeView
+			c.refresh()
+			c.render()
+		case "s":
+			c.sortOrder = (c.sortOrder + 1) % len(sortOrders)
+			c.refresh()
+			c.render()
+		case "/":
+			c.editingFilter = true
+			c.render()
+		}
+	})
+
+	ui.Handle("/timer/1s", func(ui.Event) {
+		c.refresh()
+		c.render()
+	})
+
+	ui.Handle("/sys/wnd/resize", func(e ui.Event) {
+		ui.Body.Width = e.Data.(ui.EvtWnd).Width
+		c.layout()
+	})
+}
+
+// Handle a key press while the name filter is being typed in; the list is
+// filtered as you type
+func (c *Console) editFilter(key string) {
+	switch key {
+	case "<enter>":
+		c.editingFilter = false
+	case "<escape>":
+		c.editingFilter = false
+		c.nameFilter = ""
+	case "<backspace>", "C-8":
+		if runes := []rune(c.nameFilter); len(runes) > 0 {
+			c.nameFilter = string(runes[:len(runes)-1])
+		}
+	case "<space>":
+		c.nameFilter += " "
+	case "C-c":
+		ui.StopLoop()
+		return
+	default:
+		// Ignore anything that is not a printable character
+		if len([]rune(key)) != 1 {
+			return
+		}
+
+		c.nameFilter += key
+	}
+
+	c.refresh()
+	c.render()
+}
+
+// Size widgets to the current terminal and redraw everything
+func (c *Console) layout() {
+	c.list.Height = ui.TermHeight() - headerHeight
+
+	ui.Body.Width = ui.TermWidth()
+	ui.Body.Align()
+
+	c.render()
+}
+
+func (c *Console) visibleRows() int {
+	rows := c.list.Height - 2
+	if rows < 1 {
+		return 1
+	}
+
+	return rows
+}
+
+func (c *Console) selectedProcess() (stat.ProcInfo, bool) {
+	if c.selected < 0 || c.selected >= len(c.processes) {
+		return stat.ProcInfo{}, false
+	}
+
+	return c.processes[c.selected], true
+}
+
+func (c *Console) move(delta int) {
+	c.selected += delta
+
+	if c.selected >= len(c.processes) {
+		c.selected = len(c.processes) - 1
+	}
+
+	if c.selected < 0 {
+		c.selected = 0
+	}
+
+	c.render()
+}
+
+func (c *Console) toggleWatch() {
+	p, ok := c.selectedProcess()
+	if !ok {
+		return
+	}
+
+	if p.Watched {
+		if err := c.dependencies.Statter.StopWatchProcess(p.PID); err != nil {
+			c.status = fmt.Sprintf("unable to stop watch for pid '%v': %v", p.PID, err)
+		} else {
+			c.status = fmt.Sprintf("watch stopped for pid '%v'", p.PID)
+		}
+	} else {
+		if err := c.dependencies.Statter.StartWatchProcess(p.PID, stat.WatchConfig{}); err != nil {
+			c.status = fmt.Sprintf("unable to start watch for pid '%v': %v", p.PID, err)
+		} else {
+			c.status = fmt.Sprintf("watch started for pid '%v'", p.PID)
+		}
+	}
+
+	c.refresh()
+	c.render()
+}
+
+// Re-read the process list, keeping the same PID selected if it is still around
+func (c *Console) refresh() {
+	var selectedPID int32 = -1
+
+	if p, ok := c.selectedProcess(); ok {
+		selectedPID = p.PID
+	}
+
+	filter := stat.ProcessFilter{
+		Name:        c.nameFilter,
+		WatchedOnly: c.watchedOnly,
+		Sort:        sortOrders[c.sortOrder],
+	}
+
+	if c.treeView {
+		tree, err := c.dependencies.Statter.GetProcessTree(filter)
+		if err != nil {
+			c.status = fmt.Sprintf("unable to fetch process tree: %v", err)
+			return
+		}
+
+		c.processes = make([]stat.ProcInfo, 0)
+		c.depths = make([]int, 0)
+
+		c.flatten(tree, 0)
+	} else {
+		page, err := c.dependencies.Statter.FindProcesses(filter)
+		if err != nil {
+			c.status = fmt.Sprintf("unable to fetch process list: %v", err)
+			return
+		}
+
+		c.processes = page.Processes
+		c.depths = nil
+	}
+
+	for i, p := range c.processes {
+		if p.PID == selectedPID {
+			c.selected = i
+			return
+		}
+	}
+
+	if c.selected >= len(c.processes) {
+		c.selected = len(c.processes) - 1
+	}
+
+	if c.selected < 0 {
+		c.selected = 0
+	}
+}
+
+// Append a (sub)tree depth first; entries show the usage of their whole subtree
+func (c *Console) flatten(nodes []stat.ProcessNode, depth int) {
+	for _, node := range nodes {
+		p := node.ProcInfo
+		p.CPU = node.TreeCPU
+		p.RSS = node.TreeRSS
+
+		c.processes = append(c.processes, p)
+		c.depths = append(c.depths, depth)
+
+		c.flatten(node.Children, depth+1)
+	}
+}
+
+func (c *Console) render() {
+	c.renderHeader()
+	c.renderList()
+	c.renderMetrics()
+
+	ui.Render(ui.Body)
+}
+
+func (c *Console) renderHeader() {
+	help := "q: quit | ↑/↓ j/k: select | enter/space: watch/unwatch | w: toggle watched only | /: filter | s: sort | t: tree"
+
+	if c.editingFilter {
+		help = fmt.Sprintf("filter: %v_ | enter: done | esc: clear", c.nameFilter)
+	}
+
+	if c.status != "" {
+		c.header.Text = fmt.Sprintf("%v | %v", help, c.status)
+	} else {
+		c.header.Text = help
+	}
+}
+
+func (c *Console) renderList() {
+	rows := c.visibleRows()
+
+	// Keep the selected row on screen
+	if c.selected < c.offset {
+		c.offset = c.selected
+	}
+
+	if c.selected >= c.offset+rows {
+		c.offset = c.selected - rows + 1
+	}
+
+	items := make([]string, 0, rows)
+
+	for i := c.offset; i < len(c.processes) && i < c.offset+rows; i++ {
+		p := c.processes[i]
+
+		marker := " "
+		if p.Watched {
+			marker = "*"
+		}
+
+		name := p.Name
+
+		if c.treeView {
+			name = strings.Repeat("  ", c.depths[i]) + name
+		}
+
+		line := fmt.Sprintf("%v %-7d %-10.10s %-1.1s %5.1f %9s %-20.20s %v",
+			marker, p.PID, p.User, p.State, p.CPU, humanizeBytes(p.RSS), name, p.CmdLine)
+
+		if i == c.selected {
+			// Brackets would be interpreted as termui markup
+			line = strings.NewReplacer("[", "(", "]", ")").Replace(line)
+			line = fmt.Sprintf("[%v](fg-black,bg-green)", line)
+		}
+
+		items = append(items, line)
+	}
+
+	c.list.Items = items
+	label := fmt.Sprintf("Processes (%d) sort: %v", len(c.processes), sortOrders[c.sortOrder])
+
+	if c.treeView {
+		label = fmt.Sprintf("Process tree (%d) usage incl. children", len(c.processes))
+	}
+
+	if c.nameFilter != "" {
+		label += fmt.Sprintf(" filter: %v", c.nameFilter)
+	}
+
+	c.list.BorderLabel = label
+}
+
+func (c *Console) renderMetrics() {
+	for i := range c.sparklines.Lines {
+		c.sparklines.Lines[i].Data = []int{}
+	}
+
+	c.sparklines.Lines[0].Title = "CPU"
+	c.sparklines.Lines[1].Title = "RSS"
+	c.sparklines.Lines[2].Title = "Threads"
+
+	p, ok := c.selectedProcess()
+	if !ok {
+		c.details.Text = "No process selected"
+		return
+	}
+
+	if !p.Watched {
+		c.details.Text = fmt.Sprintf("PID: %v (parent: %v)\nName: %v\nCmdLine: %v\nStarted: %v\nNot watched - press enter to start watching",
+			p.PID, p.PPID, p.Name, p.CmdLine, formatStartTime(p.StartTime))
+		return
+	}
+
+	procInfo, err := c.dependencies.Statter.GetStatsForPID(p.PID, 0)
+	if err != nil {
+		c.details.Text = fmt.Sprintf("PID: %v\nName: %v\n\nunable to fetch stats: %v", p.PID, p.Name, err)
+		return
+	}
+
+	c.details.Text = fmt.Sprintf("PID: %v\nName: %v\nCmdLine: %v\nSamples: %v",
+		procInfo.PID, procInfo.Name, procInfo.CmdLine, len(procInfo.Metrics))
+
+	if len(procInfo.Metrics) == 0 {
+		return
+	}
+
+	latest := procInfo.Metrics[len(procInfo.Metrics)-1]
+
+	c.details.Text += fmt.Sprintf("\nDisk I/O: read %v/s, write %v/s",
+		humanizeBytes(uint64(latest.ReadBytesPerSec)), humanizeBytes(uint64(latest.WriteBytesPerSec)))
+
+	c.details.Text += fmt.Sprintf("\nLast sample: %v/%v ctx switches (vol/invol), %v/%v faults (min/maj), %.3fs run queue wait",
+		latest.VoluntaryCtxSwitchesDelta, latest.InvoluntaryCtxSwitchesDelta,
+		latest.MinorFaultsDelta, latest.MajorFaultsDelta, latest.RunQueueWaitDelta)
+
+	c.details.Text += fmt.Sprintf("\nFDs: %v (limit %v/%v): %v files, %v sockets, %v pipes, %v anon inodes",
+		latest.FDs, latest.FDSoftLimit, latest.FDHardLimit,
+		latest.FileFDs, latest.SocketFDs, latest.PipeFDs, latest.AnonInodeFDs)
+
+	cpu := make([]int, 0, len(procInfo.Metrics))
+	rss := make([]int, 0, len(procInfo.Metrics))
+	threads := make([]int, 0, len(procInfo.Metrics))
+
+	for _, m := range procInfo.Metrics {
+		// Sparklines only deal with ints; keep 2 decimals worth of CPU precision
+		cpu = append(cpu, int(m.CPU*100))
+		rss = append(rss, int(m.RSS/1024))
+		threads = append(threads, int(m.Threads))
+	}
+
+	c.sparklines.Lines[0].Data = cpu
+	c.sparklines.Lines[0].Title = fmt.Sprintf("CPU %.1f%%", latest.CPU)
+	c.sparklines.Lines[1].Data = rss
+	c.sparklines.Lines[1].Title = fmt.Sprintf("RSS %v", humanizeBytes(latest.RSS))
+	c.sparklines.Lines[2].Data = threads
+	c.sparklines.Lines[2].Title = fmt.Sprintf("Threads %v", latest.Threads)
+}
+
+func formatStartTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%v (%v ago)", t.Format("2006-01-02 15:04:05"), time.Since(*t).Truncate(time.Second))
+}
+
+func humanizeBytes(b uint64) string {
+	const unit = 1024
+
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -2,24 +2,71 @@ package deps
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/gobuffalo/packr/v2"
+	"go.uber.org/zap"
 
+	"github.com/dselans/pidstat/config"
+	"github.com/dselans/pidstat/fleet"
 	"github.com/dselans/pidstat/stat"
+	"github.com/dselans/pidstat/util"
 )
 
+var (
+	sugar *zap.SugaredLogger
+)
+
+func init() {
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "deps"})
+	if err != nil {
+		panic(fmt.Sprintf("unable to setup logger: %v", err))
+	}
+
+	sugar = logger.Sugar()
+}
+
 type Dependencies struct {
+	Config   *config.Config
 	Statter  stat.Statter
 	PackrBox *packr.Box
+
+	// Only set in server mode
+	Fleet *fleet.Fleet
+
+	// Pid every configured watch was last started for; reloads leave these
+	// alone so that watches stopped by hand stay stopped
+	startedWatches map[config.Watch]int32
 }
 
-func New() (*Dependencies, error) {
-	d := &Dependencies{}
+// New sets up all dependencies from 'cfg'; if a data dir is configured,
+// watches and metrics are persisted to it
+func New(cfg *config.Config) (*Dependencies, error) {
+	d := &Dependencies{
+		Config:         cfg,
+		startedWatches: make(map[config.Watch]int32, 0),
+	}
+
+	if err := util.SetLoggerMode(cfg.ProductionLogging); err != nil {
+		return nil, fmt.Errorf("unable to setup logger: %v", err)
+	}
+
+	var storage stat.Storage
+
+	// Setup (optional) persistent storage
+	if cfg.DataDir != "" {
+		var err error
+
+		storage, err = stat.NewFileStorage(cfg.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to instantiate storage: %v", err)
+		}
+	}
 
 	// Setup process statter
-	p, err := stat.New()
+	p, err := stat.New(storage, cfg.Settings())
 	if err != nil {
-		return nil, fmt.Errorf("unable to instan3tiate stat: %v", err)
+		return nil, fmt.Errorf("unable to instantiate stat: %v", err)
 	}
 
 	d.Statter = p
@@ -27,5 +74,76 @@ func New() (*Dependencies, error) {
 	// Setup assets
 	d.PackrBox = packr.New("assets", "../assets")
 
+	d.startWatches(cfg.Watches)
+
 	return d, nil
 }
+
+// Reload applies a new config at runtime; listen address, TLS, CORS,
+// credential + data dir changes only take effect after a restart
+func (d *Dependencies) Reload(cfg *config.Config) error {
+	if cfg.ListenAddress != d.Config.ListenAddress {
+		sugar.Warnf("listen address change to '%v' requires a restart", cfg.ListenAddress)
+	}
+
+	if cfg.TLSCert != d.Config.TLSCert || cfg.TLSKey != d.Config.TLSKey || cfg.TLSSelfSigned != d.Config.TLSSelfSigned {
+		sugar.Warn("TLS changes require a restart")
+	}
+
+	if !reflect.DeepEqual(cfg.CORSOrigins, d.Config.CORSOrigins) {
+		sugar.Warn("CORS origin changes require a restart")
+	}
+
+	if !reflect.DeepEqual(cfg.Credentials, d.Config.Credentials) {
+		sugar.Warn("credential changes require a restart")
+	}
+
+	if cfg.DataDir != d.Config.DataDir {
+		sugar.Warnf("data dir change to '%v' requires a restart", cfg.DataDir)
+	}
+
+	if err := util.SetLoggerMode(cfg.ProductionLogging); err != nil {
+		return fmt.Errorf("unable to setup logger: %v", err)
+	}
+
+	if err := d.Statter.Reconfigure(cfg.Settings()); err != nil {
+		return fmt.Errorf("unable to reconfigure stat: %v", err)
+	}
+
+	d.startWatches(cfg.Watches)
+
+	d.Config = cfg
+
+	return nil
+}
+
+// Start configured watches that are new, changed or now point at a different
+// process (ie. a pid file that was rewritten); failures are not fatal as the
+// process may simply not be running (yet) and are retried on the next reload
+func (d *Dependencies) startWatches(watches []config.Watch) {
+	started := make(map[config.Watch]int32, len(watches))
+
+	for _, w := range watches {
+		pid, err := w.ResolvePID()
+		if err != nil {
+			sugar.Warnf("unable to start configured watch: %v", err)
+			continue
+		}
+
+		// Started before - may have been stopped by hand since
+		if prev, ok := d.startedWatches[w]; ok && prev == pid {
+			started[w] = pid
+			continue
+		}
+
+		err = d.Statter.StartWatchProcess(pid, w.WatchConfig)
+		if err != nil && err != stat.AlreadyWatchedErr {
+			sugar.Warnf("unable to start configured watch for pid '%v': %v", pid, err)
+			continue
+		}
+
+		started[w] = pid
+	}
+
+	d.startedWatches = started
+}
@@ -0,0 +1,69 @@
+package deps
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dselans/pidstat/config"
+	"github.com/dselans/pidstat/stat"
+)
+
+func isWatched(t *testing.T, d *Dependencies, pid int32) bool {
+	watched, err := d.Statter.GetWatchedProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range watched {
+		if w.PID == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestReloadKeepsManualStops(t *testing.T) {
+	pid := int32(os.Getpid())
+
+	cfg := config.Default()
+	cfg.Watches = []config.Watch{{PID: pid}}
+
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isWatched(t, d, pid) {
+		t.Fatal("configured watch was not started")
+	}
+
+	if err := d.Statter.StopWatchProcess(pid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged watch is not restarted
+	reloaded := config.Default()
+	reloaded.Watches = []config.Watch{{PID: pid}}
+
+	if err := d.Reload(reloaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if isWatched(t, d, pid) {
+		t.Fatal("reload restarted a watch that was stopped by hand")
+	}
+
+	// Changed watch is
+	changed := config.Default()
+	changed.Watches = []config.Watch{{PID: pid, WatchConfig: stat.WatchConfig{Interval: stat.Duration(time.Second)}}}
+
+	if err := d.Reload(changed); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isWatched(t, d, pid) {
+		t.Error("reload did not start a changed watch")
+	}
+}
@@ -1,6 +1,6 @@
 // GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
 // This file was generated by swaggo/swag at
-// 2018-12-30 18:22:18.422817658 -0800 PST m=+0.055544369
+// 2026-10-17 06:32:24.630659606 +0000 UTC m=+0.073489579
 
 package docs
 
@@ -23,24 +23,44 @@ var doc = `{
         "version": "1.0"
     },
     "paths": {
-        "/api/process": {
-            "get": {
-                "description": "Get a list of all running processes; details include PID, name and cmd line args",
+        "/api/agent/push": {
+            "post": {
+                "description": "commands (start/update/stop watch) queued for the agent. Only available in server mode.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "pid"
+                    "hosts"
+                ],
+                "summary": "Push samples (agent)",
+                "parameters": [
+                    {
+                        "description": "Process list + new samples",
+                        "name": "push",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/fleet.Push"
+                        }
+                    }
                 ],
-                "summary": "Get all running processes",
                 "responses": {
                     "200": {
-                        "description": "Contains zero or more process entries",
+                        "description": "Queued commands",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/stat.ProcInfo"
-                            }
+                            "type": "object",
+                            "$ref": "#/definitions/fleet.PushResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid push (incl. invalid host name or a push that is too large)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
                         }
                     },
                     "500": {
@@ -53,52 +73,70 @@ var doc = `{
                 }
             }
         },
-        "/api/process/{pid}": {
+        "/api/alerts": {
             "get": {
-                "description": "Get metrics for a watched process by ID",
+                "description": "Get all firing alerts and recently resolved ones (newest first)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "pid"
+                    "alerts"
                 ],
-                "summary": "Get metrics for a watched process",
+                "summary": "Get alerts",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Process ID (int)",
-                        "name": "pid",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Fetch metrics at offset",
-                        "name": "offset",
+                        "description": "Only return alerts in this state (firing or resolved)",
+                        "name": "state",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Process metrics",
+                        "description": "Contains zero or more alerts",
                         "schema": {
-                            "type": "object",
-                            "$ref": "#/definitions/stat.ProcInfo"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.Alert"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid PID (not int?)",
+                        "description": "Invalid state",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
                     },
-                    "404": {
-                        "description": "PID is not being watched",
+                    "500": {
+                        "description": "Unexpected server error",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/api/alerts/rules": {
+            "get": {
+                "description": "Get all alert rules; rules are evaluated against every new sample of the watches they apply to",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alerts"
+                ],
+                "summary": "Get all alert rules",
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more alert rules",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.AlertRule"
+                            }
+                        }
                     },
                     "500": {
                         "description": "Unexpected server error",
@@ -110,40 +148,46 @@ var doc = `{
                 }
             },
             "post": {
-                "description": "Start process watch for a specific PID",
+                "description": "and resolves as soon as it no longer holds (or the watch ends).",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "pid"
+                    "alerts"
                 ],
-                "summary": "Start process watch",
+                "summary": "Add an alert rule",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Process ID (int)",
-                        "name": "pid",
-                        "in": "path",
-                        "required": true
+                        "description": "Alert rule (id is generated if not set)",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.AlertRule"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Watch has been started for pid",
+                        "description": "Created alert rule",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/api.StatusResponse"
+                            "$ref": "#/definitions/stat.AlertRule"
                         }
                     },
                     "400": {
-                        "description": "Invalid PID (not int?)",
+                        "description": "Invalid alert rule",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
                     },
                     "409": {
-                        "description": "PID is already being watched",
+                        "description": "Alert rule with this ID already exists",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
@@ -157,46 +201,71 @@ var doc = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/alerts/rules/{id}": {
             "delete": {
-                "description": "Stop process watch for a specific PID",
+                "description": "Delete an alert rule by ID; alerts firing for it are resolved",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "pid"
+                    "alerts"
                 ],
-                "summary": "Stop process watch",
+                "summary": "Delete an alert rule",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Process ID (int)",
-                        "name": "pid",
+                        "description": "Alert rule ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Watch has been stopped for pid",
+                        "description": "Alert rule has been deleted",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
                     },
-                    "400": {
-                        "description": "Invalid PID (not int?)",
+                    "404": {
+                        "description": "Alert rule does not exist",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
                     },
-                    "404": {
-                        "description": "PID is not being watched",
+                    "500": {
+                        "description": "Unexpected server error",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/api.StatusResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/api/hosts": {
+            "get": {
+                "description": "Get every host that has pushed to this server (server mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Get all hosts",
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more hosts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/fleet.HostInfo"
+                            }
+                        }
                     },
                     "500": {
                         "description": "Unexpected server error",
@@ -208,74 +277,2321 @@ var doc = `{
                 }
             }
         },
-        "/api/version": {
+        "/api/hosts/{host}/process": {
             "get": {
-                "description": "Another simple handler, similar to '/' - if this does not work, something is broken",
+                "description": "and pagination as /api/process",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "basic"
+                    "hosts"
+                ],
+                "summary": "Get processes of a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose name contains this (case-insensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose cmd line contains this (case-insensitive)",
+                        "name": "cmdline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Treat 'name' and 'cmdline' as regular expressions",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes owned by this user",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include watched processes",
+                        "name": "watched",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by pid (default), name, cpu or rss; prefix with '-' for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return at most this many processes",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor for the next page (from X-Next-Cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Returns the current version of pidstat (api)",
                 "responses": {
                     "200": {
-                        "description": "Returns the build version. Super simple endpoint -- if this doesn't work, something is busted",
+                        "description": "Contains zero or more processes",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.ProcInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter, sort, limit or cursor",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/api.VersionResponse"
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
                         }
                     }
                 }
             }
         },
-        "/docs/index.html": {
+        "/api/hosts/{host}/process/tree": {
             "get": {
-                "description": "This endpoint serves the API spec via Swagger-UI (using github.com/swaggo/swag)",
+                "description": "/api/process/tree",
                 "produces": [
-                    "text/html"
+                    "application/json"
                 ],
                 "tags": [
-                    "basic"
+                    "hosts"
+                ],
+                "summary": "Get the process tree of a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose name contains this (case-insensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose cmd line contains this (case-insensitive)",
+                        "name": "cmdline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Treat 'name' and 'cmdline' as regular expressions",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes owned by this user",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include watched processes",
+                        "name": "watched",
+                        "in": "query"
+                    }
                 ],
-                "summary": "View API docs via Swagger-UI",
                 "responses": {
                     "200": {
-                        "description": "Swagger-UI",
+                        "description": "Contains zero or more top level processes",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.ProcessNode"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.StatusResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "string"
-                }
-            }
-        },
-        "api.VersionResponse": {
-            "type": "object",
-            "properties": {
-                "version": {
-                    "type": "string"
-                }
-            }
+        },
+        "/api/hosts/{host}/process/{pid}": {
+            "get": {
+                "description": "same as for /api/process/{pid} (server mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Get metrics for a process on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Fetch metrics at offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or after this time (RFC3339 or unix seconds)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or before this time (RFC3339 or unix seconds)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resample to one averaged sample per step (ie. '1m' or seconds)",
+                        "name": "step",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return at most this many (most recent) samples",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Process metrics",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.ProcInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int), invalid offset or invalid time range query",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist or PID has not been watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Invalid offset (too high)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Queue a sampling interval change for a watch on a host (server mode only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Update process watch on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New watch settings",
+                        "name": "config",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.UpdateWatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Watch update has been queued",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid settings",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Queue a watch start for a process on a host; it is executed once the agent pushes next (server mode only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Start process watch on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Watch configuration",
+                        "name": "config",
+                        "in": "body",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.WatchConfig"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Watch start has been queued",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid watch config",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Queue a watch stop for a process on a host (server mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Stop process watch on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Watch stop has been queued",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/hosts/{host}/process/{pid}/report": {
+            "get": {
+                "description": "host (server mode only)",
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "text/html"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Get a report for a process on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Report format: json (default), csv or html",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Report",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/report.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid format",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist or PID has not been watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/hosts/{host}/process/{pid}/summary": {
+            "get": {
+                "description": "host (server mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "hosts"
+                ],
+                "summary": "Get summary statistics for a process on a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or after this time (RFC3339 or unix seconds)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or before this time (RFC3339 or unix seconds)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Summary statistics",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.Summary"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid time window",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Host does not exist or PID has not been watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process": {
+            "get": {
+                "description": "processes matching the filter.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get all running processes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose name contains this (case-insensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose cmd line contains this (case-insensitive)",
+                        "name": "cmdline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Treat 'name' and 'cmdline' as regular expressions",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes owned by this user",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include watched processes",
+                        "name": "watched",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by pid (default), name, cpu or rss; prefix with '-' for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return at most this many processes",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor for the next page (from X-Next-Cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more process entries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.ProcInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter, sort, limit or cursor",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/history": {
+            "get": {
+                "description": "DELETE /api/process/{pid}/history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get all finished watches",
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more finished watches",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.WatchHistory"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/tree": {
+            "get": {
+                "description": "The filters of /api/process can be used; ancestors of matching processes are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get the process tree",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose name contains this (case-insensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes whose cmd line contains this (case-insensitive)",
+                        "name": "cmdline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Treat 'name' and 'cmdline' as regular expressions",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include processes owned by this user",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only include watched processes",
+                        "name": "watched",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more top level processes",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.ProcessNode"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}": {
+            "get": {
+                "description": "with 'offset'); downsampled periods are then represented by their bucket averages.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get metrics for a watched process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Fetch metrics at offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or after this time (RFC3339 or unix seconds)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or before this time (RFC3339 or unix seconds)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resample to one averaged sample per step (ie. '1m' or seconds)",
+                        "name": "step",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return at most this many (most recent) samples",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Process metrics",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.ProcInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int), invalid offset or invalid time range query",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched (and has no stored history)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Invalid offset (too high)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Change the sampling interval of a running watch; already collected metrics are kept",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Update process watch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New watch settings",
+                        "name": "config",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.UpdateWatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Watch has been updated",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid settings",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Start process watch for a specific PID; the (optional) body configures the watch",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Start process watch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Watch configuration (retention policy, sampling 'interval', 'tree', 'smaps' and 'threads' toggles)",
+                        "name": "config",
+                        "in": "body",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.WatchConfig"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Watch has been started for pid",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid watch config",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "PID is already being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop process watch for a specific PID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Stop process watch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Watch has been stopped for pid",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/fds": {
+            "get": {
+                "description": "processes owned by the user pidstat runs as can be read (unless it runs as root).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get open file descriptors of a process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Open fds, ordered by fd",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.FileDescriptor"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not permitted to read the fds of the process",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/history": {
+            "get": {
+                "description": "Offset works the same as for /api/process/{pid}.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get the history of a finished watch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Fetch metrics at offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Finished watch",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.WatchHistory"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int) or invalid offset",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID has no finished watch",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Invalid offset (too high)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a finished watch along with its (stored) metrics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Delete the history of a finished watch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "History has been deleted",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID has no finished watch",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/report": {
+            "get": {
+                "description": "row per sample; 'html' is a self-contained page with embedded charts (suitable for attaching to tickets).",
+                "produces": [
+                    "application/json",
+                    "text/plain",
+                    "text/html"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get a report for a watched process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Report format: json (default), csv or html",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Report",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/report.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid format",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched (and has no stored history)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/stream": {
+            "get": {
+                "description": "client is not keeping up and an 'end' event is sent once the watch ends.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Stream metrics for a watched process (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of samples",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.ProcInfoMetrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/summary": {
+            "get": {
+                "description": "(each bucket counts as its number of samples at its average, so stddev and percentiles are approximate).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get summary statistics for a watched process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or after this time (RFC3339 or unix seconds)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include samples at or before this time (RFC3339 or unix seconds)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Summary statistics",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.Summary"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?) or invalid time window",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched (and has no stored history)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/threads": {
+            "get": {
+                "description": "Threads that have exited are dropped.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Get per-thread metrics of a watched process",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Threads, ordered by tid",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.ThreadInfo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Watch does not collect per-thread metrics",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/process/{pid}/ws": {
+            "get": {
+                "description": "('metrics', 'dropped' and finally 'end' once the watch ends). Anything sent by the client is ignored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pid"
+                ],
+                "summary": "Stream metrics for a watched process (WebSocket)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Process ID (int)",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Stream of messages",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StreamMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid PID (not int?)",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Origin is not one of the configured CORS origins",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "PID is not being watched",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/rules": {
+            "get": {
+                "description": "Get all watch rules; every process matching a rule is watched automatically",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rules"
+                ],
+                "summary": "Get all watch rules",
+                "responses": {
+                    "200": {
+                        "description": "Contains zero or more rules",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/stat.WatchRule"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "not re-attached for the same pid.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rules"
+                ],
+                "summary": "Add a watch rule",
+                "parameters": [
+                    {
+                        "description": "Rule (id is generated if not set)",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.WatchRule"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created rule",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/stat.WatchRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid rule",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rule with this ID already exists",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/rules/{id}": {
+            "delete": {
+                "description": "Delete a watch rule by ID; watches that were already started by the rule keep running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rules"
+                ],
+                "summary": "Delete a watch rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule has been deleted",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/version": {
+            "get": {
+                "description": "Another simple handler, similar to '/' - if this does not work, something is broken",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "basic"
+                ],
+                "summary": "Returns the current version of pidstat (api)",
+                "responses": {
+                    "200": {
+                        "description": "Returns the build version. Super simple endpoint -- if this doesn't work, something is busted",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.VersionResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/docs/index.html": {
+            "get": {
+                "description": "This endpoint serves the API spec via Swagger-UI (using github.com/swaggo/swag)",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "basic"
+                ],
+                "summary": "View API docs via Swagger-UI",
+                "responses": {
+                    "200": {
+                        "description": "Swagger-UI",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "every series is labelled by pid, name and cmdline",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "metrics"
+                ],
+                "summary": "Prometheus metrics for watched processes",
+                "responses": {
+                    "200": {
+                        "description": "Prometheus metrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected server error",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/api.StatusResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.StreamMessage": {
+            "type": "object",
+            "properties": {
+                "dropped": {
+                    "description": "Set for \"dropped\" messages; total number of samples dropped so far\nbecause the client was not keeping up",
+                    "type": "integer"
+                },
+                "metrics": {
+                    "description": "Set for \"metrics\" messages",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ProcInfoMetrics"
+                },
+                "type": {
+                    "description": "One of \"metrics\", \"dropped\" or \"end\"",
+                    "type": "string"
+                }
+            }
+        },
+        "api.UpdateWatchRequest": {
+            "type": "object",
+            "properties": {
+                "interval": {
+                    "description": "New sampling interval (ie. \"500ms\", \"1m\")",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                }
+            }
+        },
+        "api.VersionResponse": {
+            "type": "object",
+            "properties": {
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "fleet.Command": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "One of ActionStart, ActionUpdate (interval only) or ActionStop",
+                    "type": "string"
+                },
+                "config": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.WatchConfig"
+                },
+                "pid": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fleet.HostInfo": {
+            "type": "object",
+            "properties": {
+                "last_seen": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "online": {
+                    "type": "boolean"
+                },
+                "processes": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                },
+                "watched": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fleet.Push": {
+            "type": "object",
+            "properties": {
+                "host": {
+                    "type": "string"
+                },
+                "processes": {
+                    "description": "Full (cached) process list of the host",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfo"
+                    }
+                },
+                "push_interval": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "version": {
+                    "type": "string"
+                },
+                "watches": {
+                    "description": "Every active watch; Metrics only contains samples since the last push",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfo"
+                    }
+                }
+            }
+        },
+        "fleet.PushResponse": {
+            "type": "object",
+            "properties": {
+                "commands": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/fleet.Command"
+                    }
+                }
+            }
+        },
+        "report.Report": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "cmd_line": {
+                    "type": "string"
+                },
+                "generated_at": {
+                    "type": "string"
+                },
+                "metrics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pid": {
+                    "type": "integer"
+                },
+                "run": {
+                    "description": "Only set for commands launched via 'pidstat run'",
+                    "type": "object",
+                    "$ref": "#/definitions/report.RunInfo"
+                },
+                "summary": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Summary"
+                },
+                "watched": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "report.RunInfo": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "duration": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "exit_code": {
+                    "type": "integer"
+                },
+                "exited_at": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "stat.Alert": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "metric": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "pid": {
+                    "type": "integer"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                },
+                "rule_name": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "stat.AlertRule": {
+            "type": "object",
+            "properties": {
+                "aggregate": {
+                    "description": "How samples in the window are combined: \"last\" (default), \"avg\" or\n\"delta\" (newest - oldest)",
+                    "type": "string"
+                },
+                "for": {
+                    "description": "Condition has to hold for this long before the alert fires",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "id": {
+                    "description": "Generated if not set",
+                    "type": "string"
+                },
+                "metric": {
+                    "description": "JSON name of a sample field (ie. \"rss\", \"cpu\", \"threads\")",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Human readable description (included in notifications)",
+                    "type": "string"
+                },
+                "op": {
+                    "description": "One of \u003e, \u003e=, \u003c, \u003c=",
+                    "type": "string"
+                },
+                "pid": {
+                    "description": "Only evaluate for this pid; 0 means every watched process",
+                    "type": "integer"
+                },
+                "samples": {
+                    "description": "Number of most recent samples in the window (default: 1, \"delta\" needs 2+)",
+                    "type": "integer"
+                },
+                "threshold": {
+                    "type": "number"
+                }
+            }
+        },
+        "stat.Duration": {
+            "type": "object"
+        },
+        "stat.ExitInfo": {
+            "type": "object",
+            "properties": {
+                "core_dumped": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "description": "Why collection failed (ExitReasonError only)",
+                    "type": "string"
+                },
+                "exit_code": {
+                    "description": "Only known if the process was still a zombie when its exit was noticed",
+                    "type": "integer"
+                },
+                "exited_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "One of ExitReasonExited, ExitReasonKilled, ExitReasonGone or ExitReasonError",
+                    "type": "string"
+                },
+                "signal": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stat.FileDescriptor": {
+            "type": "object",
+            "properties": {
+                "fd": {
+                    "type": "integer"
+                },
+                "local_address": {
+                    "type": "string"
+                },
+                "protocol": {
+                    "description": "Sockets only (if found in the network namespace of the process); unix\nsockets use the bound path (if any) as local address",
+                    "type": "string"
+                },
+                "remote_address": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "TCP only, ie. LISTEN, ESTABLISHED, CLOSE_WAIT",
+                    "type": "string"
+                },
+                "target": {
+                    "description": "Path for files, ie. \"socket:[1234]\", \"pipe:[1234]\" or\n\"anon_inode:[eventfd]\" for everything else",
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "stat.MetricsBucket": {
+            "type": "object",
+            "properties": {
+                "avg": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ProcInfoMetrics"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "max": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ProcInfoMetrics"
+                },
+                "min": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ProcInfoMetrics"
+                },
+                "samples": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
         },
         "stat.ProcInfo": {
             "type": "object",
             "properties": {
+                "buckets": {
+                    "description": "Downsampled history; only included when the requested offset reaches\npast the raw samples that are still retained",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfo"
+                    }
+                },
+                "cmd_line": {
+                    "type": "string"
+                },
+                "cpu": {
+                    "type": "number"
+                },
+                "interval": {
+                    "description": "Effective sampling interval of the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "metrics": {
+                    "description": "Available only in Proc.Metrics",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_offset": {
+                    "description": "Offset to use for fetching only new metrics on the next request",
+                    "type": "integer"
+                },
+                "pid": {
+                    "description": "Available in both Stat.processList AND Proc.Metrics",
+                    "type": "integer"
+                },
+                "ppid": {
+                    "description": "Parent pid + when the process was started",
+                    "type": "integer"
+                },
+                "retention": {
+                    "description": "Effective retention policy for the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.RetentionPolicy"
+                },
+                "rss": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "description": "ID of the rule that started the watch (if any)",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "tree_buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "tree_metrics": {
+                    "description": "Tree watches only: summed metrics of the process + all of its children\n(lined up with Metrics and Buckets) and every current child with its own metrics for the same ticks",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "user": {
+                    "description": "Process list only: owner, state (R, S, D, Z, T, ...) + resource usage\nas of the last refresh (CPU is averaged over the process list interval)",
+                    "type": "string"
+                },
+                "watched": {
+                    "type": "boolean"
+                },
+                "watched_since": {
+                    "description": "When the watch was started; tells a watch that was restarted on the same\npid apart from the previous one",
+                    "type": "string"
+                }
+            }
+        },
+        "stat.ProcInfoMetrics": {
+            "type": "object",
+            "properties": {
+                "anon_inode_fds": {
+                    "type": "integer"
+                },
+                "anonymous": {
+                    "type": "integer"
+                },
+                "cpu": {
+                    "type": "number"
+                },
+                "fd_hard_limit": {
+                    "type": "integer"
+                },
+                "fd_soft_limit": {
+                    "type": "integer"
+                },
+                "fds": {
+                    "description": "Open fds (by type) + soft/hard RLIMIT_NOFILE (0 means unlimited); fds\nare only readable by the process owner (or root)",
+                    "type": "integer"
+                },
+                "file_fds": {
+                    "type": "integer"
+                },
+                "involuntary_ctx_switches": {
+                    "type": "integer"
+                },
+                "involuntary_ctx_switches_delta": {
+                    "type": "integer"
+                },
+                "major_faults": {
+                    "type": "integer"
+                },
+                "major_faults_delta": {
+                    "type": "integer"
+                },
+                "minor_faults": {
+                    "type": "integer"
+                },
+                "minor_faults_delta": {
+                    "type": "integer"
+                },
+                "pipe_fds": {
+                    "type": "integer"
+                },
+                "pss": {
+                    "description": "Memory accounting from /proc/\u003cpid\u003e/smaps_rollup (bytes); only collected\nfor watches with WatchConfig.Smaps. PSS splits shared pages evenly\nbetween the processes sharing them, USS only counts private pages.",
+                    "type": "integer"
+                },
+                "read_bytes": {
+                    "description": "Disk I/O counters (cumulative, from /proc/\u003cpid\u003e/io)",
+                    "type": "integer"
+                },
+                "read_bytes_per_sec": {
+                    "description": "Disk I/O throughput since the previous sample (bytes/sec)",
+                    "type": "number"
+                },
+                "read_count": {
+                    "type": "integer"
+                },
+                "rss": {
+                    "type": "integer"
+                },
+                "run_queue_wait": {
+                    "description": "Time spent runnable but waiting for a CPU (cumulative seconds, from\n/proc/\u003cpid\u003e/schedstat; 0 if the kernel has no schedstats)",
+                    "type": "number"
+                },
+                "run_queue_wait_delta": {
+                    "type": "number"
+                },
+                "shared_clean": {
+                    "type": "integer"
+                },
+                "shared_dirty": {
+                    "type": "integer"
+                },
+                "socket_fds": {
+                    "type": "integer"
+                },
+                "swap": {
+                    "type": "integer"
+                },
+                "threads": {
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "uss": {
+                    "type": "integer"
+                },
+                "vms": {
+                    "type": "integer"
+                },
+                "voluntary_ctx_switches": {
+                    "description": "Context switches + page faults (cumulative, from /proc/\u003cpid\u003e/status and\n/proc/\u003cpid\u003e/stat)",
+                    "type": "integer"
+                },
+                "voluntary_ctx_switches_delta": {
+                    "description": "Increase of the above counters since the previous sample",
+                    "type": "integer"
+                },
+                "write_bytes": {
+                    "type": "integer"
+                },
+                "write_bytes_per_sec": {
+                    "type": "number"
+                },
+                "write_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stat.ProcessNode": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "Downsampled history; only included when the requested offset reaches\npast the raw samples that are still retained",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "children": {
+                    "description": "Ordered by pid",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcessNode"
+                    }
+                },
                 "cmd_line": {
                     "type": "string"
                 },
+                "cpu": {
+                    "type": "number"
+                },
+                "descendants": {
+                    "description": "Number of (transitive) descendants",
+                    "type": "integer"
+                },
+                "interval": {
+                    "description": "Effective sampling interval of the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
                 "metrics": {
                     "description": "Available only in Proc.Metrics",
                     "type": "array",
@@ -286,38 +2602,324 @@ var doc = `{
                 "name": {
                     "type": "string"
                 },
+                "next_offset": {
+                    "description": "Offset to use for fetching only new metrics on the next request",
+                    "type": "integer"
+                },
                 "pid": {
                     "description": "Available in both Stat.processList AND Proc.Metrics",
                     "type": "integer"
                 },
+                "ppid": {
+                    "description": "Parent pid + when the process was started",
+                    "type": "integer"
+                },
+                "retention": {
+                    "description": "Effective retention policy for the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.RetentionPolicy"
+                },
+                "rss": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "description": "ID of the rule that started the watch (if any)",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "tree_buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "tree_cpu": {
+                    "description": "Summed usage of the process + all of its descendants",
+                    "type": "number"
+                },
+                "tree_metrics": {
+                    "description": "Tree watches only: summed metrics of the process + all of its children\n(lined up with Metrics and Buckets) and every current child with its own metrics for the same ticks",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "tree_rss": {
+                    "type": "integer"
+                },
+                "user": {
+                    "description": "Process list only: owner, state (R, S, D, Z, T, ...) + resource usage\nas of the last refresh (CPU is averaged over the process list interval)",
+                    "type": "string"
+                },
                 "watched": {
                     "type": "boolean"
+                },
+                "watched_since": {
+                    "description": "When the watch was started; tells a watch that was restarted on the same\npid apart from the previous one",
+                    "type": "string"
                 }
             }
         },
-        "stat.ProcInfoMetrics": {
+        "stat.RetentionPolicy": {
             "type": "object",
             "properties": {
-                "cpu": {
-                    "type": "number"
+                "bucket_size": {
+                    "type": "integer"
                 },
-                "rss": {
+                "max_age": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "max_buckets": {
                     "type": "integer"
                 },
-                "swap": {
+                "max_samples": {
+                    "type": "integer"
+                }
+            }
+        },
+        "stat.Summary": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "metrics": {
+                    "description": "Keyed by the JSON name of the sample field (ie. \"rss\", \"cpu\")",
+                    "type": "object"
+                },
+                "samples": {
                     "type": "integer"
                 },
-                "threads": {
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "stat.ThreadInfo": {
+            "type": "object",
+            "properties": {
+                "metrics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ThreadMetrics"
+                    }
+                },
+                "name": {
+                    "description": "comm of the thread as of the last sample (threads can rename themselves)",
+                    "type": "string"
+                },
+                "tid": {
                     "type": "integer"
+                }
+            }
+        },
+        "stat.ThreadMetrics": {
+            "type": "object",
+            "properties": {
+                "cpu": {
+                    "description": "CPU usage since the previous sample in percent (100 == one full core)",
+                    "type": "number"
+                },
+                "state": {
+                    "description": "R, S, D, Z, T, ...",
+                    "type": "string"
+                },
+                "system_time": {
+                    "type": "number"
                 },
                 "timestamp": {
                     "type": "string"
                 },
-                "vms": {
+                "user_time": {
+                    "description": "Cumulative CPU time (seconds)",
+                    "type": "number"
+                }
+            }
+        },
+        "stat.WatchConfig": {
+            "type": "object",
+            "properties": {
+                "interval": {
+                    "description": "How often a sample is collected (default: Settings.StatInterval)",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "retention": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.RetentionPolicy"
+                },
+                "smaps": {
+                    "description": "Also collect PSS, USS, shared + anonymous memory from smaps; noticeably\nmore expensive for processes with many mappings",
+                    "type": "boolean"
+                },
+                "threads": {
+                    "description": "Also sample every thread of the process (name, state + CPU time)",
+                    "type": "boolean"
+                },
+                "tree": {
+                    "description": "Also watch all descendants of the process",
+                    "type": "boolean"
+                }
+            }
+        },
+        "stat.WatchHistory": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "Downsampled history; only included when the requested offset reaches\npast the raw samples that are still retained",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfo"
+                    }
+                },
+                "cmd_line": {
+                    "type": "string"
+                },
+                "cpu": {
+                    "type": "number"
+                },
+                "exit": {
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ExitInfo"
+                },
+                "interval": {
+                    "description": "Effective sampling interval of the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.Duration"
+                },
+                "metrics": {
+                    "description": "Available only in Proc.Metrics",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_offset": {
+                    "description": "Offset to use for fetching only new metrics on the next request",
+                    "type": "integer"
+                },
+                "peak": {
+                    "description": "Highest value of every metric over the lifetime of the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.ProcInfoMetrics"
+                },
+                "pid": {
+                    "description": "Available in both Stat.processList AND Proc.Metrics",
+                    "type": "integer"
+                },
+                "ppid": {
+                    "description": "Parent pid + when the process was started",
+                    "type": "integer"
+                },
+                "retention": {
+                    "description": "Effective retention policy for the watch",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.RetentionPolicy"
+                },
+                "rss": {
                     "type": "integer"
+                },
+                "rule": {
+                    "description": "ID of the rule that started the watch (if any)",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "tree_buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.MetricsBucket"
+                    }
+                },
+                "tree_metrics": {
+                    "description": "Tree watches only: summed metrics of the process + all of its children\n(lined up with Metrics and Buckets) and every current child with its own metrics for the same ticks",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/stat.ProcInfoMetrics"
+                    }
+                },
+                "user": {
+                    "description": "Process list only: owner, state (R, S, D, Z, T, ...) + resource usage\nas of the last refresh (CPU is averaged over the process list interval)",
+                    "type": "string"
+                },
+                "watched": {
+                    "type": "boolean"
+                },
+                "watched_since": {
+                    "description": "When the watch was started; tells a watch that was restarted on the same\npid apart from the previous one",
+                    "type": "string"
+                }
+            }
+        },
+        "stat.WatchRule": {
+            "type": "object",
+            "properties": {
+                "cmdLineRegex": {
+                    "type": "string"
+                },
+                "cmd_line": {
+                    "description": "Regular expression matched against the full cmdline",
+                    "type": "string"
+                },
+                "config": {
+                    "description": "Config used for watches started by this rule",
+                    "type": "object",
+                    "$ref": "#/definitions/stat.WatchConfig"
+                },
+                "exe": {
+                    "description": "Glob (see filepath.Match) matched against the full path of the executable",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Generated if not set",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Glob (see filepath.Match) matched against the process name",
+                    "type": "string"
+                },
+                "user": {
+                    "description": "Name of the user owning the process",
+                    "type": "string"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "BasicAuth": {
+            "type": "basic"
+        },
+        "BearerToken": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -0,0 +1,485 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dselans/pidstat/stat"
+	"github.com/dselans/pidstat/util"
+)
+
+const (
+	// Per-host data lives in <data dir>/hosts/<host>
+	HostsDir = "hosts"
+
+	// A host is considered offline after missing this many pushes
+	OfflineAfterPushes = 3
+
+	ActionStart  = "start"
+	ActionUpdate = "update"
+	ActionStop   = "stop"
+)
+
+var (
+	HostNotFoundErr = errors.New("host does not exist")
+	InvalidHostErr  = errors.New("invalid host name")
+
+	// Host names are used as dir names
+	validHostName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+	sugar *zap.SugaredLogger
+)
+
+func init() {
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "fleet"})
+	if err != nil {
+		panic(fmt.Sprintf("unable to setup logger: %v", err))
+	}
+
+	sugar = logger.Sugar()
+}
+
+// Push is sent by an agent on every push interval
+type Push struct {
+	Host         string        `json:"host"`
+	Version      string        `json:"version,omitempty"`
+	PushInterval stat.Duration `json:"push_interval"`
+
+	// Full (cached) process list of the host
+	Processes []stat.ProcInfo `json:"processes"`
+
+	// Every active watch; Metrics only contains samples since the last push
+	Watches []stat.ProcInfo `json:"watches"`
+}
+
+// PushResponse contains commands queued for the agent since its last push
+type PushResponse struct {
+	Commands []Command `json:"commands"`
+}
+
+// Command is executed by the agent against its local statter
+type Command struct {
+	// One of ActionStart, ActionUpdate (interval only) or ActionStop
+	Action string           `json:"action"`
+	PID    int32            `json:"pid"`
+	Config stat.WatchConfig `json:"config"`
+}
+
+// HostInfo describes a host that has pushed to the server
+type HostInfo struct {
+	Name      string    `json:"name"`
+	Version   string    `json:"version,omitempty"`
+	LastSeen  time.Time `json:"last_seen"`
+	Online    bool      `json:"online"`
+	Processes int       `json:"processes"`
+	Watched   int       `json:"watched"`
+}
+
+type host struct {
+	name         string
+	version      string
+	lastSeen     time.Time
+	pushInterval time.Duration
+
+	processes []stat.ProcInfo
+	storage   stat.Storage
+
+	// Newest stored sample per pid; used to drop samples that were re-sent
+	lastSample map[int32]time.Time
+
+	// Commands waiting for the next push
+	commands []Command
+}
+
+// Fleet stores what agents push, per host
+type Fleet struct {
+	// Empty means hosts are kept in memory only
+	dataDir string
+
+	hosts map[string]*host
+	lock  *sync.Mutex
+}
+
+// New creates a Fleet; hosts that pushed to a previous instance (using the
+// same data dir) are restored as offline
+func New(dataDir string) (*Fleet, error) {
+	f := &Fleet{
+		dataDir: dataDir,
+		hosts:   make(map[string]*host, 0),
+		lock:    &sync.Mutex{},
+	}
+
+	if dataDir == "" {
+		return f, nil
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(dataDir, HostsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+
+		return nil, fmt.Errorf("unable to read hosts dir: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !validHostName.MatchString(entry.Name()) {
+			continue
+		}
+
+		h, err := f.newHost(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		f.hosts[h.name] = h
+	}
+
+	return f, nil
+}
+
+func (f *Fleet) newHost(name string) (*host, error) {
+	h := &host{
+		name:       name,
+		processes:  make([]stat.ProcInfo, 0),
+		lastSample: make(map[int32]time.Time, 0),
+		commands:   make([]Command, 0),
+	}
+
+	if f.dataDir == "" {
+		h.storage = stat.NewMemoryStorage(0)
+		return h, nil
+	}
+
+	storage, err := stat.NewFileStorage(filepath.Join(f.dataDir, HostsDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open storage for host '%v': %v", name, err)
+	}
+
+	h.storage = storage
+
+	// Continue where the previous instance left off
+	records, err := storage.GetWatches()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load watches for host '%v': %v", name, err)
+	}
+
+	for _, record := range records {
+		metrics, err := storage.GetMetrics(record.PID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load metrics for host '%v': %v", name, err)
+		}
+
+		if len(metrics) > 0 {
+			h.lastSample[record.PID] = metrics[len(metrics)-1].Timestamp
+		}
+	}
+
+	return h, nil
+}
+
+func (h *host) online() bool {
+	return time.Since(h.lastSeen) < OfflineAfterPushes*h.pushInterval
+}
+
+// Push stores everything an agent sent and returns its queued commands
+func (f *Fleet) Push(push Push) (PushResponse, error) {
+	if !validHostName.MatchString(push.Host) {
+		return PushResponse{}, InvalidHostErr
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	h, ok := f.hosts[push.Host]
+	if !ok {
+		var err error
+
+		h, err = f.newHost(push.Host)
+		if err != nil {
+			return PushResponse{}, err
+		}
+
+		f.hosts[push.Host] = h
+
+		sugar.Infof("new host '%v'", push.Host)
+	}
+
+	h.version = push.Version
+	h.lastSeen = time.Now()
+	h.pushInterval = time.Duration(push.PushInterval)
+
+	if push.Processes != nil {
+		h.processes = push.Processes
+	}
+
+	if err := h.storeWatches(push.Watches); err != nil {
+		return PushResponse{}, fmt.Errorf("unable to store watches for host '%v': %v", h.name, err)
+	}
+
+	response := PushResponse{
+		Commands: h.commands,
+	}
+
+	h.commands = make([]Command, 0)
+
+	return response, nil
+}
+
+func (h *host) storeWatches(watches []stat.ProcInfo) error {
+	active := make(map[int32]struct{}, len(watches))
+
+	for _, w := range watches {
+		active[w.PID] = struct{}{}
+
+		record, err := h.storage.GetWatch(w.PID)
+
+		isNew := err != nil || !record.Active || !sameWatch(record, w)
+
+		if isNew {
+			// New watch (or the pid is watched again) - start from scratch
+			if err := h.storage.ResetMetrics(w.PID); err != nil {
+				return err
+			}
+
+			delete(h.lastSample, w.PID)
+
+			record = stat.WatchRecord{
+				PID:       w.PID,
+				Name:      w.Name,
+				CmdLine:   w.CmdLine,
+				Rule:      w.Rule,
+				StartedAt: time.Now(),
+				Active:    true,
+			}
+
+			if w.WatchedSince != nil {
+				record.StartedAt = *w.WatchedSince
+			}
+
+			if w.StartTime != nil {
+				record.CreateTime = w.StartTime.UnixNano() / int64(time.Millisecond)
+			}
+		}
+
+		updated := record
+		updated.Config.Interval = w.Interval
+
+		if w.Retention != nil {
+			updated.Config.Retention = *w.Retention
+		}
+
+		// Only write when something changed (FileStorage rewrites all records)
+		if isNew || updated != record {
+			if err := h.storage.SaveWatch(updated); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range w.Metrics {
+			if !m.Timestamp.After(h.lastSample[w.PID]) {
+				continue
+			}
+
+			if err := h.storage.AppendMetrics(w.PID, m); err != nil {
+				return err
+			}
+
+			h.lastSample[w.PID] = m.Timestamp
+		}
+	}
+
+	// Watches that are no longer active on the agent
+	records, err := h.storage.GetWatches()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, ok := active[record.PID]; ok || !record.Active {
+			continue
+		}
+
+		record.Active = false
+
+		if err := h.storage.SaveWatch(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Does a pushed watch belong to the stored record? The agent may have
+// restarted the watch (or the pid may have been re-used) between two pushes.
+func sameWatch(record stat.WatchRecord, w stat.ProcInfo) bool {
+	if w.WatchedSince != nil && !w.WatchedSince.Equal(record.StartedAt) {
+		return false
+	}
+
+	if w.StartTime != nil && record.CreateTime != 0 && w.StartTime.UnixNano()/int64(time.Millisecond) != record.CreateTime {
+		return false
+	}
+
+	return true
+}
+
+// Get all hosts, sorted by name
+func (f *Fleet) GetHosts() ([]HostInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	hosts := make([]HostInfo, 0, len(f.hosts))
+
+	for _, h := range f.hosts {
+		watched, err := h.activeWatches()
+		if err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, HostInfo{
+			Name:      h.name,
+			Version:   h.version,
+			LastSeen:  h.lastSeen,
+			Online:    h.online(),
+			Processes: len(h.processes),
+			Watched:   len(watched),
+		})
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	return hosts, nil
+}
+
+func (h *host) activeWatches() (map[int32]struct{}, error) {
+	records, err := h.storage.GetWatches()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[int32]struct{}, 0)
+
+	for _, record := range records {
+		if record.Active {
+			watched[record.PID] = struct{}{}
+		}
+	}
+
+	return watched, nil
+}
+
+// Get the last pushed process list of a host (with up to date watch state)
+func (f *Fleet) GetProcesses(name string) ([]stat.ProcInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	h, ok := f.hosts[name]
+	if !ok {
+		return nil, HostNotFoundErr
+	}
+
+	watched, err := h.activeWatches()
+	if err != nil {
+		return nil, err
+	}
+
+	processes := make([]stat.ProcInfo, 0, len(h.processes))
+
+	for _, p := range h.processes {
+		_, p.Watched = watched[p.PID]
+		processes = append(processes, p)
+	}
+
+	return processes, nil
+}
+
+// Get stored metrics for a (current or past) watch on a host; offset works
+// the same as for stat.Statter.GetStatsForPID
+func (f *Fleet) GetStatsForPID(name string, pid int32, offset int) (stat.ProcInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	h, ok := f.hosts[name]
+	if !ok {
+		return stat.ProcInfo{}, HostNotFoundErr
+	}
+
+	procInfo, err := stat.StoredStatsForPID(h.storage, pid, offset)
+	if err != nil {
+		return stat.ProcInfo{}, err
+	}
+
+	record, err := h.storage.GetWatch(pid)
+	if err != nil {
+		return stat.ProcInfo{}, err
+	}
+
+	procInfo.Watched = record.Active
+
+	return procInfo, nil
+}
+
+// Get metrics for a (current or past) watch on a host selected by time; works
+// the same as stat.Statter.QueryStatsForPID
+func (f *Fleet) QueryStatsForPID(name string, pid int32, query stat.Query) (stat.ProcInfo, error) {
+	if err := query.Validate(); err != nil {
+		return stat.ProcInfo{}, err
+	}
+
+	procInfo, err := f.GetStatsForPID(name, pid, 0)
+	if err != nil {
+		return stat.ProcInfo{}, err
+	}
+
+	return query.Apply(procInfo), nil
+}
+
+// Get summary statistics for a (current or past) watch on a host; works the
+// same as stat.Statter.GetSummaryForPID
+func (f *Fleet) GetSummaryForPID(name string, pid int32, from, to time.Time) (stat.Summary, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return stat.Summary{}, fmt.Errorf("'to' (%v) is before 'from' (%v)", to, from)
+	}
+
+	procInfo, err := f.GetStatsForPID(name, pid, 0)
+	if err != nil {
+		return stat.Summary{}, err
+	}
+
+	return stat.SummarizeWindow(procInfo.Metrics, procInfo.Buckets, from, to), nil
+}
+
+// Queue a command for a host; it is delivered with the response to the next push
+func (f *Fleet) QueueCommand(name string, command Command) error {
+	switch command.Action {
+	case ActionStart, ActionUpdate, ActionStop:
+	default:
+		return fmt.Errorf("invalid action '%v'", command.Action)
+	}
+
+	if err := command.Config.Validate(); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	h, ok := f.hosts[name]
+	if !ok {
+		return HostNotFoundErr
+	}
+
+	h.commands = append(h.commands, command)
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+var pushStart = time.Unix(1000, 0)
+
+// Pushed watch for pid 1 with 'n' samples taken 'first'.. seconds after pushStart
+func pushedWatch(watchedSince, startTime time.Time, first, n int) stat.ProcInfo {
+	w := stat.ProcInfo{PID: 1, Name: "test", WatchedSince: &watchedSince, StartTime: &startTime}
+
+	for i := first; i < first+n; i++ {
+		w.Metrics = append(w.Metrics, stat.ProcInfoMetrics{
+			RSS:       uint64(i),
+			Timestamp: pushStart.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	return w
+}
+
+func TestPushSeparatesRestartedWatches(t *testing.T) {
+	watchedSince := pushStart
+	startTime := pushStart.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		second stat.ProcInfo
+		want   int
+	}{
+		{"same watch", pushedWatch(watchedSince, startTime, 3, 2), 5},
+		{"same watch, re-sent samples", pushedWatch(watchedSince, startTime, 1, 4), 5},
+		{"watch restarted on the same pid", pushedWatch(watchedSince.Add(10*time.Second), startTime, 0, 2), 2},
+		{"pid re-used", pushedWatch(watchedSince, startTime.Add(time.Minute), 0, 2), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New("")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, w := range []stat.ProcInfo{pushedWatch(watchedSince, startTime, 0, 3), tt.second} {
+				if _, err := f.Push(Push{Host: "host", Watches: []stat.ProcInfo{w}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			procInfo, err := f.GetStatsForPID("host", 1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(procInfo.Metrics) != tt.want {
+				t.Fatalf("expected %v samples, got %v", tt.want, len(procInfo.Metrics))
+			}
+
+			if !procInfo.WatchedSince.Equal(*tt.second.WatchedSince) {
+				t.Errorf("watched since = %v, want %v", procInfo.WatchedSince, tt.second.WatchedSince)
+			}
+
+			if last := procInfo.Metrics[len(procInfo.Metrics)-1]; last.RSS != tt.second.Metrics[len(tt.second.Metrics)-1].RSS {
+				t.Errorf("expected newest pushed sample to be stored, got rss %v", last.RSS)
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 module github.com/dselans/pidstat
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/gizak/termui v2.3.0+incompatible
 	github.com/go-chi/chi v3.3.3+incompatible
-	github.com/go-chi/cors v1.0.0 // indirect
+	github.com/go-chi/cors v1.0.0
 	github.com/go-openapi/jsonreference v0.17.2 // indirect
 	github.com/go-openapi/spec v0.17.2 // indirect
 	github.com/gobuffalo/packr/v2 v2.0.0-rc.8
+	github.com/maruel/panicparse v1.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.4 // indirect
+	github.com/mitchellh/go-wordwrap v1.0.0 // indirect
+	github.com/nsf/termbox-go v0.0.0-20181027232701-60ab7e3d12ed // indirect
 	github.com/relistan/go-director v0.0.0-20181104164737-5f56787d9731
 	github.com/shirou/gopsutil v2.18.11+incompatible
 	github.com/swaggo/files v0.0.0-20180215091130-49c8a91ea3fa // indirect
@@ -16,4 +22,7 @@ require (
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
 	go.uber.org/zap v1.9.1
+	golang.org/x/net v0.0.0-20181114220301-adae6a3d119a
+	golang.org/x/sys v0.0.0-20190222072716-a9d3bda3a223
+	gopkg.in/yaml.v2 v2.2.1
 )
@@ -6,33 +6,67 @@
 //
 // @license.name MIT
 // @license.url http://opensource.org/licenses/MIT
+//
+// @securityDefinitions.basic BasicAuth
+//
+// @securityDefinitions.apikey BearerToken
+// @in header
+// @name Authorization
 package main
 
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/dselans/pidstat/deps"
 	"github.com/urfave/cli"
 	"go.uber.org/zap"
 
+	"github.com/dselans/pidstat/agent"
 	"github.com/dselans/pidstat/api"
+	"github.com/dselans/pidstat/config"
+	"github.com/dselans/pidstat/console"
+	"github.com/dselans/pidstat/fleet"
+	"github.com/dselans/pidstat/report"
+	"github.com/dselans/pidstat/runner"
+	"github.com/dselans/pidstat/stat"
 	"github.com/dselans/pidstat/util"
 )
 
 const (
-	DefaultRunMode       = "web"
-	DefaultListenAddress = ":8787"
+	DefaultRunMode = "web"
 )
 
 var (
-	sugar         *zap.SugaredLogger
-	version       string
-	listenAddress string
+	sugar             *zap.SugaredLogger
+	version           string
+	configFile        string
+	listenAddress     string
+	tlsCert           string
+	tlsKey            string
+	tlsSelfSigned     bool
+	corsOrigins       cli.StringSlice
+	agentServer       string
+	agentHost         string
+	agentInsecure     bool
+	agentPushInterval time.Duration
+	logFile           string
+	dataDir           string
+	reportFile        string
+	reportFormat      string
+	interval          time.Duration
+	smaps             bool
+	alertCommand      string
+	alertWebhook      string
 )
 
 func init() {
-	logger, err := util.CreateLogger(false, map[string]interface{}{"pkg": "main"})
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "main"})
 	if err != nil {
 		panic(fmt.Sprintf("unable to setup logger: %v", err))
 	}
@@ -53,26 +87,185 @@ func main() {
 		app.Version = version
 	}
 
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "config",
+			EnvVar:      config.EnvPrefix + "CONFIG",
+			Usage:       "load settings from this YAML, TOML or JSON file (flags take precedence)",
+			Destination: &configFile,
+		},
+	}
+
+	// Alert delivery is available in every mode
+	alertFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:        "alert-command",
+			Usage:       "run this command (via 'sh -c') for every alert that fires or resolves",
+			Destination: &alertCommand,
+		},
+		cli.StringFlag{
+			Name:        "alert-webhook",
+			Usage:       "POST every alert that fires or resolves (as JSON) to this URL",
+			Destination: &alertWebhook,
+		},
+	}
+
+	// Shared by 'web' and 'server'
+	webFlags := append([]cli.Flag{
+		cli.StringFlag{
+			Name:        "address",
+			Usage:       "bind the server to a specific server (default: " + config.DefaultListenAddress + ")",
+			Destination: &listenAddress,
+		},
+		cli.StringFlag{
+			Name:        "tls-cert",
+			Usage:       "serve HTTPS using this certificate (requires --tls-key)",
+			Destination: &tlsCert,
+		},
+		cli.StringFlag{
+			Name:        "tls-key",
+			Usage:       "private key for --tls-cert",
+			Destination: &tlsKey,
+		},
+		cli.BoolFlag{
+			Name:        "tls-self-signed",
+			Usage:       "serve HTTPS using a generated self-signed certificate",
+			Destination: &tlsSelfSigned,
+		},
+		cli.StringSliceFlag{
+			Name:  "cors-origin",
+			Usage: "allow cross-origin requests from this origin (repeatable; default: any)",
+			Value: &corsOrigins,
+		},
+		cli.StringFlag{
+			Name:        "data-dir",
+			Usage:       "persist watches + metrics to this dir (default: keep in memory only)",
+			Destination: &dataDir,
+		},
+	}, alertFlags...)
+
 	app.Commands = []cli.Command{
 		{
 			Name:    "web",
 			Aliases: []string{"w"},
 			Usage:   "start pidstat in web mode",
 			Action:  runWeb,
-			Flags: []cli.Flag{
+			Flags:   webFlags,
+		},
+		{
+			Name:   "server",
+			Usage:  "start pidstat in web mode and accept samples pushed by agents",
+			Action: runServer,
+			Flags:  webFlags,
+		},
+		{
+			Name:   "agent",
+			Usage:  "collect samples locally and push them to a server",
+			Action: runAgent,
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
-					Name:        "address",
-					Value:       DefaultListenAddress,
-					Usage:       "bind the server to a specific server",
-					Destination: &listenAddress,
+					Name:        "server",
+					Usage:       "URL of the server to push to (ie. https://pidstat.example.com:8787)",
+					Destination: &agentServer,
 				},
-			},
+				cli.StringFlag{
+					Name:        "host",
+					Usage:       "name this host reports as (default: hostname)",
+					Destination: &agentHost,
+				},
+				cli.DurationFlag{
+					Name:        "push-interval",
+					Usage:       "how often samples are pushed (default: 5s)",
+					Destination: &agentPushInterval,
+				},
+				cli.BoolFlag{
+					Name:        "insecure",
+					Usage:       "skip TLS certificate verification (ie. for self-signed servers)",
+					Destination: &agentInsecure,
+				},
+				cli.StringFlag{
+					Name:        "data-dir",
+					Usage:       "persist watches + metrics to this dir (default: keep in memory only)",
+					Destination: &dataDir,
+				},
+			}, alertFlags...),
 		},
 		{
 			Name:    "cli",
 			Aliases: []string{"c"},
 			Usage:   "start pidstat in cli mode",
 			Action:  runCLI,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:        "log-file",
+					Usage:       "write logs to this file while the dashboard is running (default: discard)",
+					Destination: &logFile,
+				},
+				cli.StringFlag{
+					Name:        "data-dir",
+					Usage:       "persist watches + metrics to this dir (default: keep in memory only)",
+					Destination: &dataDir,
+				},
+			}, alertFlags...),
+		},
+		{
+			Name:      "run",
+			Aliases:   []string{"r"},
+			Usage:     "launch a command and watch it until it exits",
+			ArgsUsage: "-- COMMAND [ARGS...]",
+			Action:    runRun,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:        "report",
+					Usage:       "write the report to this file (default: pidstat-PID.FORMAT)",
+					Destination: &reportFile,
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Value:       report.FormatJSON,
+					Usage:       "report format (" + strings.Join(report.Formats, ", ") + ")",
+					Destination: &reportFormat,
+				},
+				cli.DurationFlag{
+					Name:        "interval",
+					Usage:       "sampling interval (ie. 100ms, 1m) (default: stat_interval from config or " + stat.StatInterval.String() + ")",
+					Destination: &interval,
+				},
+				cli.BoolFlag{
+					Name:        "smaps",
+					Usage:       "also collect PSS, USS, shared + anonymous memory (more expensive)",
+					Destination: &smaps,
+				},
+				cli.StringFlag{
+					Name:        "data-dir",
+					Usage:       "persist watches + metrics to this dir (default: keep in memory only)",
+					Destination: &dataDir,
+				},
+			}, alertFlags...),
+		},
+		{
+			Name:      "report",
+			Usage:     "generate a report for a (current or past) watch stored in a data dir",
+			ArgsUsage: "PID",
+			Action:    runReport,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "data-dir",
+					Usage:       "data dir used by 'web', 'cli' or 'run' (required, unless set in config)",
+					Destination: &dataDir,
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Value:       report.FormatJSON,
+					Usage:       "report format (" + strings.Join(report.Formats, ", ") + ")",
+					Destination: &reportFormat,
+				},
+				cli.StringFlag{
+					Name:        "output",
+					Usage:       "write the report to this file (default: stdout)",
+					Destination: &reportFile,
+				},
+			},
 		},
 	}
 
@@ -83,14 +276,21 @@ func main() {
 
 // Launch the app in web mode
 func runWeb(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
 	// Setup dependencies
-	d, err := deps.New()
+	d, err := deps.New(cfg)
 	if err != nil {
 		sugar.Fatalf("unable to instantiate dependencies: %v", err)
 	}
 
+	go reloadOnSIGHUP(ctx, d)
+
 	// Setup API server
-	a, err := api.New(listenAddress, ctx.App.Version, d)
+	a, err := api.New(cfg, ctx.App.Version, d)
 	if err != nil {
 		sugar.Fatalf("unable to instantiate API: %v", err)
 	}
@@ -101,8 +301,267 @@ func runWeb(ctx *cli.Context) error {
 	return nil
 }
 
+// Launch the app in web mode + accept pushes from agents
+func runServer(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
+	// Setup dependencies
+	d, err := deps.New(cfg)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate dependencies: %v", err)
+	}
+
+	// Pushed data is stored per host (under <data dir>/hosts if set)
+	d.Fleet, err = fleet.New(cfg.DataDir)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate fleet: %v", err)
+	}
+
+	go reloadOnSIGHUP(ctx, d)
+
+	// Setup API server
+	a, err := api.New(cfg, ctx.App.Version, d)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate API: %v", err)
+	}
+
+	// Run API server
+	sugar.Fatal(a.Run())
+
+	return nil
+}
+
+// Collect locally and push to a server
+func runAgent(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
+	// Setup dependencies
+	d, err := deps.New(cfg)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate dependencies: %v", err)
+	}
+
+	go reloadOnSIGHUP(ctx, d)
+
+	ag, err := agent.New(cfg, ctx.App.Version, d)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate agent: %v", err)
+	}
+
+	// Push forever
+	sugar.Fatal(ag.Run())
+
+	return nil
+}
+
 // Launch the app in CLI mode
 func runCLI(ctx *cli.Context) error {
-	sugar.Error("CLI mode not implemented yet")
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
+	// Setup dependencies
+	d, err := deps.New(cfg)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate dependencies: %v", err)
+	}
+
+	// Setup console dashboard
+	c, err := console.New(cfg.LogFile, d)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate console: %v", err)
+	}
+
+	// Run dashboard (blocks until user quits)
+	if err := c.Run(); err != nil {
+		sugar.Fatalf("unable to run console: %v", err)
+	}
+
 	return nil
 }
+
+// Launch a command and watch it until it exits
+func runRun(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
+	// Setup dependencies
+	d, err := deps.New(cfg)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate dependencies: %v", err)
+	}
+
+	// Without --interval the configured default applies
+	watchConfig := stat.WatchConfig{Smaps: smaps}
+
+	if ctx.IsSet("interval") {
+		watchConfig.Interval = stat.Duration(interval)
+	}
+
+	r, err := runner.New(ctx.Args(), reportFile, reportFormat, watchConfig, d)
+	if err != nil {
+		sugar.Fatalf("unable to instantiate runner: %v", err)
+	}
+
+	// Run command (blocks until it exits)
+	exitCode, err := r.Run()
+	if err != nil {
+		sugar.Errorf("unable to run command: %v", err)
+
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	}
+
+	// Relay the exit code of the command
+	os.Exit(exitCode)
+
+	return nil
+}
+
+// Generate a report straight from a data dir (does not need a running pidstat)
+func runReport(ctx *cli.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		sugar.Fatalf("unable to load config: %v", err)
+	}
+
+	if cfg.DataDir == "" {
+		sugar.Fatal("--data-dir is required")
+	}
+
+	if !report.ValidFormat(reportFormat) {
+		sugar.Fatalf("invalid format '%v' (expected one of %v)", reportFormat, strings.Join(report.Formats, ", "))
+	}
+
+	pid, err := strconv.ParseInt(ctx.Args().First(), 10, 32)
+	if err != nil {
+		sugar.Fatalf("invalid pid '%v': %v", ctx.Args().First(), err)
+	}
+
+	storage, err := stat.NewFileStorage(cfg.DataDir)
+	if err != nil {
+		sugar.Fatalf("unable to open data dir: %v", err)
+	}
+
+	defer storage.Close()
+
+	procInfo, err := stat.StoredStatsForPID(storage, int32(pid), 0)
+	if err != nil {
+		sugar.Fatalf("unable to load stats for pid '%v': %v", pid, err)
+	}
+
+	out := os.Stdout
+
+	if reportFile != "" {
+		out, err = os.Create(reportFile)
+		if err != nil {
+			sugar.Fatalf("unable to create report file: %v", err)
+		}
+
+		defer out.Close()
+	}
+
+	if err := report.New(procInfo).Render(out, reportFormat); err != nil {
+		sugar.Fatalf("unable to write report: %v", err)
+	}
+
+	return nil
+}
+
+// Config file + env, with explicitly set flags taking precedence
+func loadConfig(ctx *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.IsSet("address") {
+		cfg.ListenAddress = listenAddress
+	}
+
+	if ctx.IsSet("tls-cert") {
+		cfg.TLSCert = tlsCert
+	}
+
+	if ctx.IsSet("tls-key") {
+		cfg.TLSKey = tlsKey
+	}
+
+	if ctx.IsSet("tls-self-signed") {
+		cfg.TLSSelfSigned = tlsSelfSigned
+	}
+
+	if ctx.IsSet("cors-origin") {
+		cfg.CORSOrigins = corsOrigins
+	}
+
+	if ctx.IsSet("server") {
+		cfg.AgentServer = agentServer
+	}
+
+	if ctx.IsSet("host") {
+		cfg.AgentHost = agentHost
+	}
+
+	if ctx.IsSet("push-interval") {
+		cfg.AgentPushInterval = stat.Duration(agentPushInterval)
+	}
+
+	if ctx.IsSet("insecure") {
+		cfg.AgentInsecure = agentInsecure
+	}
+
+	if ctx.IsSet("data-dir") {
+		cfg.DataDir = dataDir
+	}
+
+	if ctx.IsSet("log-file") {
+		cfg.LogFile = logFile
+	}
+
+	if ctx.IsSet("alert-command") {
+		cfg.AlertCommand = alertCommand
+	}
+
+	if ctx.IsSet("alert-webhook") {
+		cfg.AlertWebhook = alertWebhook
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// Re-read the config whenever SIGHUP is received (web, server + agent mode; in
+// cli mode SIGHUP means the terminal is gone)
+func reloadOnSIGHUP(ctx *cli.Context, d *deps.Dependencies) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		cfg, err := loadConfig(ctx)
+		if err != nil {
+			sugar.Errorf("unable to reload config: %v", err)
+			continue
+		}
+
+		if err := d.Reload(cfg); err != nil {
+			sugar.Errorf("unable to apply reloaded config: %v", err)
+			continue
+		}
+
+		sugar.Info("config reloaded")
+	}
+}
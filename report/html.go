@@ -0,0 +1,209 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 160
+)
+
+// Metrics that get a chart in the HTML report
+var charts = []struct {
+	metric string
+	title  string
+}{
+	{metric: "cpu", title: "CPU (%)"},
+	{metric: "rss", title: "Resident memory"},
+	{metric: "threads", title: "Threads"},
+	{metric: "read_bytes_per_sec", title: "Disk reads (per second)"},
+	{metric: "write_bytes_per_sec", title: "Disk writes (per second)"},
+}
+
+// Metrics that are displayed in B/KiB/MiB/...
+var byteMetrics = map[string]bool{
+	"rss":                 true,
+	"vms":                 true,
+	"swap":                true,
+	"read_bytes":          true,
+	"write_bytes":         true,
+	"read_bytes_per_sec":  true,
+	"write_bytes_per_sec": true,
+	"pss":                 true,
+	"uss":                 true,
+	"shared_clean":        true,
+	"shared_dirty":        true,
+	"anonymous":           true,
+}
+
+type htmlChart struct {
+	Title  string
+	Points string
+	Min    string
+	Max    string
+}
+
+type htmlSummaryRow struct {
+	Metric string
+	Min    string
+	Mean   string
+	P90    string
+	Max    string
+	Last   string
+}
+
+type htmlData struct {
+	*Report
+
+	Width  int
+	Height int
+	Charts []htmlChart
+	Rows   []htmlSummaryRow
+}
+
+// Everything (styles + charts) is inline so the file can be attached as-is
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pidstat report: {{.Name}} ({{.PID}})</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.chart { margin-bottom: 2em; }
+.chart svg { border: 1px solid #ccc; background: #fafafa; }
+.chart polyline { fill: none; stroke: #2a7ae2; stroke-width: 1.5; }
+.axis { color: #666; font-size: 0.85em; }
+code { background: #f0f0f0; padding: 2px 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Name}} (pid {{.PID}})</h1>
+<p><code>{{.CmdLine}}</code></p>
+<p>{{.Report.Summary.Samples}} samples from {{.Report.Summary.Start.Format "2006-01-02 15:04:05 MST"}} to {{.Report.Summary.End.Format "2006-01-02 15:04:05 MST"}}
+({{.DurationString}}); generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+{{with .Run}}<p>Launched {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}, exited with code {{.ExitCode}} after {{$.RunDurationString}}</p>
+{{end}}
+<h2>Summary</h2>
+<table>
+<tr><th>Metric</th><th>Min</th><th>Mean</th><th>p90</th><th>Max</th><th>Last</th></tr>
+{{range .Rows}}<tr><td>{{.Metric}}</td><td>{{.Min}}</td><td>{{.Mean}}</td><td>{{.P90}}</td><td>{{.Max}}</td><td>{{.Last}}</td></tr>
+{{end}}</table>
+
+{{$width := .Width}}{{$height := .Height}}
+{{range .Charts}}<div class="chart">
+<h3>{{.Title}}</h3>
+<div class="axis">max: {{.Max}}</div>
+<svg width="{{$width}}" height="{{$height}}" viewBox="0 0 {{$width}} {{$height}}"><polyline points="{{.Points}}"/></svg>
+<div class="axis">min: {{.Min}}</div>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// DurationString is used by the HTML template
+func (d htmlData) DurationString() string {
+	return time.Duration(d.Report.Summary.Duration).String()
+}
+
+// RunDurationString is used by the HTML template
+func (d htmlData) RunDurationString() string {
+	return time.Duration(d.Report.Run.Duration).String()
+}
+
+func (r *Report) renderHTML(w io.Writer) error {
+	data := htmlData{
+		Report: r,
+		Width:  chartWidth,
+		Height: chartHeight,
+	}
+
+	// Summary metrics are keyed by name; keep the rows in field order
+	for _, name := range stat.MetricNames() {
+		s := r.Summary.Metrics[name]
+
+		data.Rows = append(data.Rows, htmlSummaryRow{
+			Metric: name,
+			Min:    formatValue(name, s.Min),
+			Mean:   formatValue(name, s.Mean),
+			P90:    formatValue(name, s.P90),
+			Max:    formatValue(name, s.Max),
+			Last:   formatValue(name, s.Last),
+		})
+	}
+
+	for _, c := range charts {
+		s := r.Summary.Metrics[c.metric]
+
+		data.Charts = append(data.Charts, htmlChart{
+			Title:  c.title,
+			Points: r.chartPoints(c.metric, s.Min, s.Max),
+			Min:    formatValue(c.metric, s.Min),
+			Max:    formatValue(c.metric, s.Max),
+		})
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// SVG polyline points; x is time, y is scaled between min and max
+func (r *Report) chartPoints(metric string, min, max float64) string {
+	if len(r.Metrics) == 0 {
+		return ""
+	}
+
+	start := r.Metrics[0].Timestamp
+	span := r.Metrics[len(r.Metrics)-1].Timestamp.Sub(start).Seconds()
+
+	points := make([]string, 0, len(r.Metrics))
+
+	for i, m := range r.Metrics {
+		v, _ := stat.MetricValue(m, metric)
+
+		x := 0.0
+
+		if span > 0 {
+			x = m.Timestamp.Sub(start).Seconds() / span * chartWidth
+		} else if len(r.Metrics) > 1 {
+			x = float64(i) / float64(len(r.Metrics)-1) * chartWidth
+		}
+
+		// Flat line in the middle if there is nothing to scale
+		y := chartHeight / 2.0
+
+		if max > min {
+			y = chartHeight - (v-min)/(max-min)*(chartHeight-10) - 5
+		}
+
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+
+	return strings.Join(points, " ")
+}
+
+func formatValue(metric string, v float64) string {
+	if !byteMetrics[metric] {
+		return fmt.Sprintf("%.2f", v)
+	}
+
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	i := 0
+
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %v", v, units[i])
+}
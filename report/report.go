@@ -0,0 +1,142 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dselans/pidstat/stat"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatHTML = "html"
+)
+
+// Formats lists all supported report formats
+var Formats = []string{FormatJSON, FormatCSV, FormatHTML}
+
+// Report is the JSON representation of a report
+type Report struct {
+	PID         int32                  `json:"pid"`
+	Name        string                 `json:"name"`
+	CmdLine     string                 `json:"cmd_line"`
+	Watched     bool                   `json:"watched"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Summary     stat.Summary           `json:"summary"`
+	Metrics     []stat.ProcInfoMetrics `json:"metrics"`
+	Buckets     []stat.MetricsBucket   `json:"buckets,omitempty"`
+
+	// Only set for commands launched via 'pidstat run'
+	Run *RunInfo `json:"run,omitempty"`
+}
+
+// RunInfo describes a command launched via 'pidstat run'
+type RunInfo struct {
+	Command   []string      `json:"command"`
+	StartedAt time.Time     `json:"started_at"`
+	ExitedAt  time.Time     `json:"exited_at"`
+	Duration  stat.Duration `json:"duration"`
+	ExitCode  int           `json:"exit_code"`
+}
+
+// New builds a report for everything that is retained for a watch
+func New(procInfo stat.ProcInfo) *Report {
+	metrics := procInfo.Metrics
+	if metrics == nil {
+		metrics = make([]stat.ProcInfoMetrics, 0)
+	}
+
+	return &Report{
+		PID:         procInfo.PID,
+		Name:        procInfo.Name,
+		CmdLine:     procInfo.CmdLine,
+		Watched:     procInfo.Watched,
+		GeneratedAt: time.Now(),
+		Summary:     stat.SummarizeWithBuckets(metrics, procInfo.Buckets),
+		Metrics:     metrics,
+		Buckets:     procInfo.Buckets,
+	}
+}
+
+// ValidFormat returns true if 'format' is a supported report format
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContentType returns the MIME type for a report format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+
+	return "application/json; charset=utf-8"
+}
+
+// Filename returns a sensible file name for the report
+func (r *Report) Filename(format string) string {
+	return fmt.Sprintf("pidstat-%v.%v", r.PID, format)
+}
+
+// Render writes the report in the given format
+func (r *Report) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return r.renderJSON(w)
+	case FormatCSV:
+		return r.renderCSV(w)
+	case FormatHTML:
+		return r.renderHTML(w)
+	}
+
+	return fmt.Errorf("unknown report format '%v'", format)
+}
+
+func (r *Report) renderJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// One row per sample; the summary is left out so the output stays a plain table
+func (r *Report) renderCSV(w io.Writer) error {
+	names := stat.MetricNames()
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(append([]string{"timestamp"}, names...)); err != nil {
+		return err
+	}
+
+	for _, m := range r.Metrics {
+		row := make([]string, 0, len(names)+1)
+		row = append(row, m.Timestamp.Format(time.RFC3339Nano))
+
+		for _, name := range names {
+			v, _ := stat.MetricValue(m, name)
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
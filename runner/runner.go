@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dselans/pidstat/deps"
+	"github.com/dselans/pidstat/report"
+	"github.com/dselans/pidstat/stat"
+	"github.com/dselans/pidstat/util"
+)
+
+var (
+	sugar *zap.SugaredLogger
+)
+
+func init() {
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "runner"})
+	if err != nil {
+		panic(fmt.Sprintf("unable to setup logger: %v", err))
+	}
+
+	sugar = logger.Sugar()
+}
+
+// Runner launches a command and watches it for its entire lifetime
+type Runner struct {
+	dependencies *deps.Dependencies
+	args         []string
+	reportFile   string
+	reportFormat string
+	config       stat.WatchConfig
+}
+
+// Samples of the launched command; turned into a report once it exits
+type run struct {
+	procInfo  stat.ProcInfo
+	startedAt time.Time
+}
+
+func New(args []string, reportFile, reportFormat string, config stat.WatchConfig, d *deps.Dependencies) (*Runner, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	if !report.ValidFormat(reportFormat) {
+		return nil, fmt.Errorf("invalid report format '%v'", reportFormat)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid watch config: %v", err)
+	}
+
+	return &Runner{
+		dependencies: d,
+		args:         args,
+		reportFile:   reportFile,
+		reportFormat: reportFormat,
+		config:       config,
+	}, nil
+}
+
+// Run launches the command and blocks until it exits; returns the exit code
+// of the command (128 + signal number if it was killed by a signal). A report
+// is always written, even if the command could not be watched.
+func (r *Runner) Run() (int, error) {
+	cmd := exec.Command(r.args[0], r.args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Terminal generated signals (ctrl-c, ctrl-\) already reach the command
+	// through the process group; only relay the ones sent to us directly.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	// Same exit code a shell uses for commands it cannot run
+	if err := cmd.Start(); err != nil {
+		return 127, fmt.Errorf("unable to start command: %v", err)
+	}
+
+	launched := &run{
+		procInfo: stat.ProcInfo{
+			PID:     int32(cmd.Process.Pid),
+			Name:    r.args[0],
+			CmdLine: strings.Join(r.args, " "),
+			Metrics: make([]stat.ProcInfoMetrics, 0),
+		},
+		startedAt: time.Now(),
+	}
+
+	pid := launched.procInfo.PID
+
+	// The command is running either way - keep waiting for it (and relay its
+	// exit code) even if it cannot be watched; the report is just empty then
+	var samples <-chan stat.ProcInfoMetrics
+
+	sub, err := r.watch(launched)
+	if err != nil {
+		sugar.Errorf("unable to watch command: %v", err)
+	} else {
+		samples = sub.C
+
+		defer r.dependencies.Statter.Unsubscribe(sub)
+	}
+
+	exited := make(chan error, 1)
+
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	var waitErr error
+
+	for done := false; !done; {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGTERM || sig == syscall.SIGHUP {
+				cmd.Process.Signal(sig)
+			}
+		case m, ok := <-samples:
+			if !ok {
+				// Watch ended before we noticed the exit
+				samples = nil
+				continue
+			}
+
+			launched.add(m)
+		case waitErr = <-exited:
+			done = true
+		}
+	}
+
+	exitedAt := time.Now()
+	code := exitCode(waitErr)
+
+	// Watch may already have ended on its own
+	if err := r.dependencies.Statter.StopWatchProcess(pid); err != nil && err != stat.NotWatchedErr {
+		sugar.Errorf("unable to stop watch for pid '%v': %v", pid, err)
+	}
+
+	// Pick up anything that was published before the watch ended
+	if sub != nil {
+		for m := range sub.C {
+			launched.add(m)
+		}
+	}
+
+	rep := report.New(launched.procInfo)
+	rep.Run = &report.RunInfo{
+		Command:   r.args,
+		StartedAt: launched.startedAt,
+		ExitedAt:  exitedAt,
+		Duration:  stat.Duration(exitedAt.Sub(launched.startedAt)),
+		ExitCode:  code,
+	}
+
+	if err := r.writeReport(rep); err != nil {
+		sugar.Errorf("unable to write report: %v", err)
+	}
+
+	if waitErr != nil && code < 0 {
+		return 1, fmt.Errorf("unable to wait for command: %v", waitErr)
+	}
+
+	return code, nil
+}
+
+// Start the watch + subscribe to it; samples collected before we subscribed
+// are copied into the run right away
+func (r *Runner) watch(launched *run) (*stat.Subscription, error) {
+	statter := r.dependencies.Statter
+	pid := launched.procInfo.PID
+
+	if err := statter.StartWatchProcess(pid, r.config); err != nil {
+		return nil, fmt.Errorf("unable to watch pid '%v': %v", pid, err)
+	}
+
+	sub, err := statter.Subscribe(pid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to subscribe to pid '%v': %v", pid, err)
+	}
+
+	procInfo, err := statter.GetStatsForPID(pid, 0)
+	if err != nil {
+		statter.Unsubscribe(sub)
+		return nil, fmt.Errorf("unable to fetch stats for pid '%v': %v", pid, err)
+	}
+
+	launched.procInfo.Name = procInfo.Name
+	launched.procInfo.CmdLine = procInfo.CmdLine
+
+	for _, m := range procInfo.Metrics {
+		launched.add(m)
+	}
+
+	return sub, nil
+}
+
+// Samples can be seen twice (once via GetStatsForPID and once via the
+// subscription); only keep new ones
+func (r *run) add(m stat.ProcInfoMetrics) {
+	metrics := r.procInfo.Metrics
+
+	if n := len(metrics); n > 0 && !m.Timestamp.After(metrics[n-1].Timestamp) {
+		return
+	}
+
+	r.procInfo.Metrics = append(metrics, m)
+}
+
+func (r *Runner) writeReport(rep *report.Report) error {
+	reportFile := r.reportFile
+	if reportFile == "" {
+		reportFile = rep.Filename(r.reportFormat)
+	}
+
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return err
+	}
+
+	if err := rep.Render(f, r.reportFormat); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	sugar.Infof("'%v' (pid %v) exited with code %v after %v; wrote %v sample(s) to '%v'",
+		rep.Name, rep.PID, rep.Run.ExitCode, time.Duration(rep.Run.Duration), len(rep.Metrics), reportFile)
+
+	return nil
+}
+
+// Returns -1 if the exit code cannot be determined
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return -1
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return -1
+	}
+
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return status.ExitStatus()
+}
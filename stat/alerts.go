@@ -0,0 +1,427 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of resolved alerts kept around for GetAlerts()
+	MaxResolvedAlerts = 1000
+
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+
+	AggregateLast  = "last"
+	AggregateAvg   = "avg"
+	AggregateDelta = "delta"
+)
+
+var (
+	AlertRuleNotFoundErr = errors.New("alert rule does not exist")
+	AlertRuleExistsErr   = errors.New("alert rule already exists")
+)
+
+// AlertRule is evaluated against every new sample of matching watches.
+//
+// Examples:
+//
+//	RSS > 2GiB for 1m:             {"metric": "rss", "op": ">", "threshold": 2147483648, "for": "1m"}
+//	CPU > 90% avg over 5 samples:  {"metric": "cpu", "aggregate": "avg", "samples": 5, "op": ">", "threshold": 90}
+//	Threads grew by 10+ (12 ticks): {"metric": "threads", "aggregate": "delta", "samples": 12, "op": ">=", "threshold": 10}
+type AlertRule struct {
+	// Generated if not set
+	ID string `json:"id"`
+
+	// Human readable description (included in notifications)
+	Name string `json:"name,omitempty"`
+
+	// Only evaluate for this pid; 0 means every watched process
+	PID int32 `json:"pid,omitempty"`
+
+	// JSON name of a sample field (ie. "rss", "cpu", "threads")
+	Metric string `json:"metric"`
+
+	// How samples in the window are combined: "last" (default), "avg" or
+	// "delta" (newest - oldest)
+	Aggregate string `json:"aggregate,omitempty"`
+
+	// Number of most recent samples in the window (default: 1, "delta" needs 2+)
+	Samples int `json:"samples,omitempty"`
+
+	// One of >, >=, <, <=
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+
+	// Condition has to hold for this long before the alert fires
+	For Duration `json:"for,omitempty"`
+}
+
+// Alert is a firing (or resolved) instance of an alert rule for a single pid
+type Alert struct {
+	ID         string     `json:"id"`
+	Rule       string     `json:"rule"`
+	RuleName   string     `json:"rule_name,omitempty"`
+	PID        int32      `json:"pid"`
+	Name       string     `json:"name"`
+	Metric     string     `json:"metric"`
+	Op         string     `json:"op"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Message    string     `json:"message"`
+}
+
+type alertKey struct {
+	rule string
+	pid  int32
+}
+
+type alertState struct {
+	// When the condition started holding (zero if it currently does not)
+	pendingSince time.Time
+
+	firing *Alert
+}
+
+// Validate the rule + fill in defaults
+func (r *AlertRule) Validate() error {
+	if _, ok := MetricValue(ProcInfoMetrics{}, r.Metric); !ok {
+		return fmt.Errorf("unknown metric '%v'", r.Metric)
+	}
+
+	switch r.Op {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("invalid op '%v' (expected one of >, >=, <, <=)", r.Op)
+	}
+
+	if r.Aggregate == "" {
+		r.Aggregate = AggregateLast
+	}
+
+	if r.Samples <= 0 {
+		r.Samples = 1
+	}
+
+	switch r.Aggregate {
+	case AggregateLast, AggregateAvg:
+	case AggregateDelta:
+		if r.Samples < 2 {
+			return errors.New("'delta' aggregate needs at least 2 samples")
+		}
+	default:
+		return fmt.Errorf("invalid aggregate '%v' (expected one of last, avg, delta)", r.Aggregate)
+	}
+
+	if r.For < 0 {
+		return errors.New("'for' cannot be negative")
+	}
+
+	return nil
+}
+
+// Combine the window into a single value; false if there are not enough samples yet
+func (r *AlertRule) value(window []ProcInfoMetrics) (float64, bool) {
+	if len(window) < r.Samples {
+		return 0, false
+	}
+
+	last, _ := MetricValue(window[len(window)-1], r.Metric)
+
+	switch r.Aggregate {
+	case AggregateAvg:
+		var sum float64
+
+		for _, m := range window {
+			v, _ := MetricValue(m, r.Metric)
+			sum += v
+		}
+
+		return sum / float64(len(window)), true
+	case AggregateDelta:
+		first, _ := MetricValue(window[0], r.Metric)
+		return last - first, true
+	}
+
+	return last, true
+}
+
+func (r *AlertRule) holds(v float64) bool {
+	switch r.Op {
+	case ">":
+		return v > r.Threshold
+	case ">=":
+		return v >= r.Threshold
+	case "<":
+		return v < r.Threshold
+	case "<=":
+		return v <= r.Threshold
+	}
+
+	return false
+}
+
+// MetricValue looks up a numeric sample field by its JSON name
+func MetricValue(m ProcInfoMetrics, name string) (float64, bool) {
+	if name == "" {
+		return 0, false
+	}
+
+	value := reflect.ValueOf(m)
+
+	for i := 0; i < value.NumField(); i++ {
+		tag := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		if tag != name {
+			continue
+		}
+
+		return numericValue(value.Field(i))
+	}
+
+	return 0, false
+}
+
+func (s *Stat) loadAlertRules() error {
+	rules, err := s.storage.GetAlertRules()
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		rule := rule
+
+		if err := rule.Validate(); err != nil {
+			sugar.Warnf("ignoring invalid stored alert rule '%v': %v", rule.ID, err)
+			continue
+		}
+
+		s.alertRules[rule.ID] = &rule
+	}
+
+	return nil
+}
+
+// Caller must hold alertsLock
+func (s *Stat) sortedAlertRules() []AlertRule {
+	rules := make([]AlertRule, 0, len(s.alertRules))
+
+	for _, rule := range s.alertRules {
+		rules = append(rules, *rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	return rules
+}
+
+// Get all alert rules
+func (s *Stat) GetAlertRules() ([]AlertRule, error) {
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	return s.sortedAlertRules(), nil
+}
+
+// Add an alert rule; it is evaluated starting with the next sample
+func (s *Stat) AddAlertRule(rule AlertRule) (AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return AlertRule{}, err
+	}
+
+	if rule.ID == "" {
+		id, err := generateID()
+		if err != nil {
+			return AlertRule{}, fmt.Errorf("unable to generate alert rule id: %v", err)
+		}
+
+		rule.ID = id
+	}
+
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	if _, ok := s.alertRules[rule.ID]; ok {
+		return AlertRule{}, AlertRuleExistsErr
+	}
+
+	s.alertRules[rule.ID] = &rule
+
+	if err := s.storage.SaveAlertRules(s.sortedAlertRules()); err != nil {
+		delete(s.alertRules, rule.ID)
+		return AlertRule{}, fmt.Errorf("unable to persist alert rule: %v", err)
+	}
+
+	return rule, nil
+}
+
+// Delete an alert rule; alerts firing for it are resolved
+func (s *Stat) DeleteAlertRule(id string) error {
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	rule, ok := s.alertRules[id]
+	if !ok {
+		return AlertRuleNotFoundErr
+	}
+
+	delete(s.alertRules, id)
+
+	if err := s.storage.SaveAlertRules(s.sortedAlertRules()); err != nil {
+		s.alertRules[id] = rule
+		return fmt.Errorf("unable to persist alert rules: %v", err)
+	}
+
+	for key := range s.alertStates {
+		if key.rule == id {
+			s.resolveAlert(key, "alert rule deleted")
+		}
+	}
+
+	return nil
+}
+
+// Get all firing alerts + recently resolved ones (newest first)
+func (s *Stat) GetAlerts() ([]Alert, error) {
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	alerts := make([]Alert, 0, len(s.resolvedAlerts))
+
+	for _, state := range s.alertStates {
+		if state.firing != nil {
+			alerts = append(alerts, *state.firing)
+		}
+	}
+
+	alerts = append(alerts, s.resolvedAlerts...)
+
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].StartedAt.After(alerts[j].StartedAt) })
+
+	return alerts, nil
+}
+
+// Evaluate all alert rules that apply to a watch; called for every new sample
+func (s *Stat) evaluateAlerts(procInfo ProcInfo, series *series) {
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	for _, rule := range s.alertRules {
+		if rule.PID != 0 && rule.PID != procInfo.PID {
+			continue
+		}
+
+		window := series.lastN(rule.Samples)
+
+		value, ok := rule.value(window)
+		if !ok {
+			continue
+		}
+
+		now := window[len(window)-1].Timestamp
+		key := alertKey{rule: rule.ID, pid: procInfo.PID}
+
+		state, ok := s.alertStates[key]
+		if !ok {
+			state = &alertState{}
+			s.alertStates[key] = state
+		}
+
+		if !rule.holds(value) {
+			if state.firing != nil {
+				s.resolveAlert(key, fmt.Sprintf("%v is back to %v", rule.Metric, value))
+			}
+
+			delete(s.alertStates, key)
+
+			continue
+		}
+
+		if state.pendingSince.IsZero() {
+			state.pendingSince = now
+		}
+
+		if state.firing != nil || now.Sub(state.pendingSince) < time.Duration(rule.For) {
+			continue
+		}
+
+		id, err := generateID()
+		if err != nil {
+			sugar.Errorf("unable to generate alert id: %v", err)
+			continue
+		}
+
+		state.firing = &Alert{
+			ID:        id,
+			Rule:      rule.ID,
+			RuleName:  rule.Name,
+			PID:       procInfo.PID,
+			Name:      procInfo.Name,
+			Metric:    rule.Metric,
+			Op:        rule.Op,
+			Threshold: rule.Threshold,
+			Value:     value,
+			State:     AlertFiring,
+			StartedAt: now,
+			Message: fmt.Sprintf("%v (%v) of '%v' (pid %v) is %v %v %v", rule.Metric, rule.Aggregate,
+				procInfo.Name, procInfo.PID, value, rule.Op, rule.Threshold),
+		}
+
+		s.notify(*state.firing)
+	}
+}
+
+// Resolve all alerts for a pid (watch has ended)
+func (s *Stat) endAlerts(pid int32) {
+	s.alertsLock.Lock()
+	defer s.alertsLock.Unlock()
+
+	for key := range s.alertStates {
+		if key.pid == pid {
+			s.resolveAlert(key, "watch ended")
+		}
+	}
+}
+
+// Caller must hold alertsLock
+func (s *Stat) resolveAlert(key alertKey, reason string) {
+	state, ok := s.alertStates[key]
+	if !ok {
+		return
+	}
+
+	delete(s.alertStates, key)
+
+	if state.firing == nil {
+		return
+	}
+
+	now := time.Now()
+
+	alert := *state.firing
+	alert.State = AlertResolved
+	alert.ResolvedAt = &now
+	alert.Message = fmt.Sprintf("resolved: %v", reason)
+
+	s.resolvedAlerts = append(s.resolvedAlerts, alert)
+
+	if len(s.resolvedAlerts) > MaxResolvedAlerts {
+		s.resolvedAlerts = s.resolvedAlerts[len(s.resolvedAlerts)-MaxResolvedAlerts:]
+	}
+
+	s.notify(alert)
+}
+
+// Queue an alert for every notifier; each notifier gets its alerts in order.
+// Caller must hold alertsLock (notifiers can be swapped out by Reconfigure()).
+func (s *Stat) notify(alert Alert) {
+	for _, worker := range s.notifiers {
+		worker.send(alert)
+	}
+}
@@ -0,0 +1,333 @@
+package stat
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// What an fd refers to
+	FDTypeFile      = "file"
+	FDTypeSocket    = "socket"
+	FDTypePipe      = "pipe"
+	FDTypeAnonInode = "anon_inode"
+	FDTypeOther     = "other"
+)
+
+var (
+	NoProcessErr    = errors.New("no such process")
+	FDPermissionErr = errors.New("not permitted to read the fds of the process")
+)
+
+var (
+	socketProtocols = []string{"tcp", "tcp6", "udp", "udp6"}
+
+	// st column of /proc/net/tcp
+	tcpStates = map[string]string{
+		"01": "ESTABLISHED",
+		"02": "SYN_SENT",
+		"03": "SYN_RECV",
+		"04": "FIN_WAIT1",
+		"05": "FIN_WAIT2",
+		"06": "TIME_WAIT",
+		"07": "CLOSE",
+		"08": "CLOSE_WAIT",
+		"09": "LAST_ACK",
+		"0A": "LISTEN",
+		"0B": "CLOSING",
+	}
+)
+
+// FileDescriptor is a single open fd of a process
+type FileDescriptor struct {
+	FD   int    `json:"fd"`
+	Type string `json:"type"`
+
+	// Path for files, ie. "socket:[1234]", "pipe:[1234]" or
+	// "anon_inode:[eventfd]" for everything else
+	Target string `json:"target"`
+
+	// Sockets only (if found in the network namespace of the process); unix
+	// sockets use the bound path (if any) as local address
+	Protocol      string `json:"protocol,omitempty"`
+	LocalAddress  string `json:"local_address,omitempty"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+
+	// TCP only, ie. LISTEN, ESTABLISHED, CLOSE_WAIT
+	State string `json:"state,omitempty"`
+}
+
+// What kind of thing an fd link target refers to
+func fdType(target string) string {
+	switch {
+	case strings.HasPrefix(target, "/"):
+		return FDTypeFile
+	case strings.HasPrefix(target, "socket:"):
+		return FDTypeSocket
+	case strings.HasPrefix(target, "pipe:"):
+		return FDTypePipe
+	case strings.HasPrefix(target, "anon_inode:"):
+		return FDTypeAnonInode
+	}
+
+	return FDTypeOther
+}
+
+// Read every open fd of a process (sorted by fd); only permitted for the
+// process owner (or root)
+func readFDs(pid int32) ([]FileDescriptor, error) {
+	dir := procPath(strconv.Itoa(int(pid)), "fd")
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make([]FileDescriptor, 0, len(entries))
+
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		target, err := os.Readlink(dir + "/" + entry.Name())
+		if err != nil {
+			// Closed in the meantime
+			continue
+		}
+
+		fds = append(fds, FileDescriptor{
+			FD:     fd,
+			Type:   fdType(target),
+			Target: target,
+		})
+	}
+
+	sort.Slice(fds, func(i, j int) bool { return fds[i].FD < fds[j].FD })
+
+	return fds, nil
+}
+
+// Soft + hard RLIMIT_NOFILE of a process, from /proc/<pid>/limits; 0 means
+// unlimited
+func readFDLimits(pid int32) (uint64, uint64, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "limits"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// ie. "Max open files            1024                 524288               files"
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) < 2 {
+			break
+		}
+
+		var limits [2]uint64
+
+		for i, field := range fields[:2] {
+			if field == "unlimited" {
+				continue
+			}
+
+			if limits[i], err = strconv.ParseUint(field, 10, 64); err != nil {
+				return 0, 0, fmt.Errorf("invalid open files limit: %v", err)
+			}
+		}
+
+		return limits[0], limits[1], nil
+	}
+
+	return 0, 0, errors.New("open files limit not found")
+}
+
+// Sockets in the network namespace of a process by inode
+func readSockets(pid int32) map[string]FileDescriptor {
+	sockets := make(map[string]FileDescriptor, 0)
+
+	for _, protocol := range socketProtocols {
+		// ie. ipv6 is disabled; just means there are no sockets of this kind
+		if err := readInetSockets(pid, protocol, sockets); err != nil {
+			sugar.Debugf("unable to read %v sockets of pid '%v': %v", protocol, pid, err)
+		}
+	}
+
+	if err := readUnixSockets(pid, sockets); err != nil {
+		sugar.Debugf("unable to read unix sockets of pid '%v': %v", pid, err)
+	}
+
+	return sockets
+}
+
+// Parse /proc/<pid>/net/{tcp,tcp6,udp,udp6}
+func readInetSockets(pid int32, protocol string, sockets map[string]FileDescriptor) error {
+	f, err := os.Open(procPath(strconv.Itoa(int(pid)), "net", protocol))
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	// Skip header
+	scanner.Scan()
+
+	// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		local, err := parseSocketAddress(fields[1])
+		if err != nil {
+			continue
+		}
+
+		remote, err := parseSocketAddress(fields[2])
+		if err != nil {
+			continue
+		}
+
+		socket := FileDescriptor{
+			Protocol:      protocol,
+			LocalAddress:  local,
+			RemoteAddress: remote,
+		}
+
+		if strings.HasPrefix(protocol, "tcp") {
+			socket.State = tcpStates[fields[3]]
+		}
+
+		sockets[fields[9]] = socket
+	}
+
+	return scanner.Err()
+}
+
+// Parse /proc/<pid>/net/unix
+func readUnixSockets(pid int32, sockets map[string]FileDescriptor) error {
+	f, err := os.Open(procPath(strconv.Itoa(int(pid)), "net", "unix"))
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	// Skip header
+	scanner.Scan()
+
+	// Num RefCount Protocol Flags Type St Inode [Path]
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			continue
+		}
+
+		socket := FileDescriptor{Protocol: "unix"}
+
+		if len(fields) > 7 {
+			socket.LocalAddress = fields[7]
+		}
+
+		sockets[fields[6]] = socket
+	}
+
+	return scanner.Err()
+}
+
+// Address as found in /proc/net/tcp, ie. "0100007F:1F90" (127.0.0.1:8080);
+// the ip is made up of little-endian 32 bit words
+func parseSocketAddress(s string) (string, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("unexpected address '%v'", s)
+	}
+
+	ip, err := hex.DecodeString(parts[0])
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return "", fmt.Errorf("unexpected address '%v'", s)
+	}
+
+	for i := 0; i < len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("unexpected port in address '%v'", s)
+	}
+
+	return net.JoinHostPort(net.IP(ip).String(), strconv.FormatUint(port, 10)), nil
+}
+
+// Count fds by type into a sample
+func countFDs(metrics *ProcInfoMetrics, fds []FileDescriptor) {
+	metrics.FDs = uint64(len(fds))
+
+	for _, fd := range fds {
+		switch fd.Type {
+		case FDTypeFile:
+			metrics.FileFDs++
+		case FDTypeSocket:
+			metrics.SocketFDs++
+		case FDTypePipe:
+			metrics.PipeFDs++
+		case FDTypeAnonInode:
+			metrics.AnonInodeFDs++
+		}
+	}
+}
+
+// Get the currently open fds of a process (watched or not); sockets include
+// their addresses + state
+func (s *Stat) GetFDsForPID(pid int32) ([]FileDescriptor, error) {
+	fds, err := readFDs(pid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, NoProcessErr
+		}
+
+		if os.IsPermission(err) {
+			return nil, FDPermissionErr
+		}
+
+		return nil, fmt.Errorf("unable to read fds of pid '%v': %v", pid, err)
+	}
+
+	sockets := readSockets(pid)
+
+	for i, fd := range fds {
+		if fd.Type != FDTypeSocket {
+			continue
+		}
+
+		// "socket:[1234]"
+		inode := strings.TrimSuffix(strings.TrimPrefix(fd.Target, "socket:["), "]")
+
+		if socket, ok := sockets[inode]; ok {
+			fds[i].Protocol = socket.Protocol
+			fds[i].LocalAddress = socket.LocalAddress
+			fds[i].RemoteAddress = socket.RemoteAddress
+			fds[i].State = socket.State
+		}
+	}
+
+	return fds, nil
+}
@@ -0,0 +1,226 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const (
+	// Why a watch ended
+	ExitReasonExited = "exited" // Process exited on its own (exit code is set if known)
+	ExitReasonKilled = "killed" // Process was terminated by a signal
+	ExitReasonGone   = "gone"   // Process is gone; the exit status was not obtainable
+	ExitReasonError  = "error"  // Metrics could no longer be collected
+)
+
+var (
+	NoHistoryErr = errors.New("pid has no finished watch")
+)
+
+// ExitInfo describes how the process of a finished watch ended
+type ExitInfo struct {
+	ExitedAt time.Time `json:"exited_at"`
+
+	// One of ExitReasonExited, ExitReasonKilled, ExitReasonGone or ExitReasonError
+	Reason string `json:"reason"`
+
+	// Only known if the process was still a zombie when its exit was noticed
+	ExitCode *int `json:"exit_code,omitempty"`
+	Signal   *int `json:"signal,omitempty"`
+	Core     bool `json:"core_dumped,omitempty"`
+
+	// Why collection failed (ExitReasonError only)
+	Error string `json:"error,omitempty"`
+}
+
+// WatchHistory is everything that is retained about a finished watch
+type WatchHistory struct {
+	ProcInfo
+
+	StartedAt time.Time `json:"started_at"`
+	Exit      ExitInfo  `json:"exit"`
+
+	// Highest value of every metric over the lifetime of the watch
+	Peak ProcInfoMetrics `json:"peak"`
+}
+
+// Figure out whether (and how) a watched process has exited; nil if it is
+// still running. A process whose parent has not reaped it yet is a zombie
+// whose stat still contains the exit status.
+func processExit(pid int32) *ExitInfo {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return &ExitInfo{ExitedAt: time.Now(), Reason: ExitReasonGone}
+	}
+
+	if st.State != "Z" {
+		return nil
+	}
+
+	exit := &ExitInfo{ExitedAt: time.Now(), Reason: ExitReasonGone}
+
+	if st.ExitStatus < 0 {
+		return exit
+	}
+
+	status := syscall.WaitStatus(st.ExitStatus)
+
+	switch {
+	case status.Signaled():
+		signal := int(status.Signal())
+
+		exit.Reason = ExitReasonKilled
+		exit.Signal = &signal
+		exit.Core = status.CoreDump()
+	case status.Exited():
+		exitCode := status.ExitStatus()
+
+		exit.Reason = ExitReasonExited
+		exit.ExitCode = &exitCode
+	}
+
+	return exit
+}
+
+// Get the history of a finished watch (the process has exited); offset works
+// the same as for GetStatsForPID
+func (s *Stat) GetHistoryForPID(pid int32, offset int) (WatchHistory, error) {
+	s.watchedLock.Lock()
+	proc, ok := s.finished[pid]
+	s.watchedLock.Unlock()
+
+	if ok {
+		return proc.history(offset)
+	}
+
+	// Finished before a restart
+	record, err := s.storage.GetWatch(pid)
+	if err != nil || record.Exit == nil || record.Active {
+		return WatchHistory{}, NoHistoryErr
+	}
+
+	procInfo, err := StoredStatsForPID(s.storage, pid, offset)
+	if err != nil {
+		return WatchHistory{}, err
+	}
+
+	history, err := s.storage.GetMetrics(pid)
+	if err != nil {
+		return WatchHistory{}, fmt.Errorf("unable to load stored metrics for pid '%v': %v", pid, err)
+	}
+
+	// Watches that finished while we were not running only have what is stored
+	peak := peakOf(history)
+
+	if record.Peak != nil {
+		peak = *record.Peak
+	}
+
+	return WatchHistory{
+		ProcInfo:  procInfo,
+		StartedAt: record.StartedAt,
+		Exit:      *record.Exit,
+		Peak:      peak,
+	}, nil
+}
+
+func (p *Proc) history(offset int) (WatchHistory, error) {
+	metrics, buckets, nextOffset, err := p.series.read(offset)
+	if err != nil {
+		return WatchHistory{}, err
+	}
+
+	retention := p.series.policy
+
+	procInfo := p.ProcInfo
+	procInfo.Watched = false
+	procInfo.Metrics = metrics
+	procInfo.Buckets = buckets
+	procInfo.NextOffset = nextOffset
+	procInfo.Retention = &retention
+
+	return WatchHistory{
+		ProcInfo:  procInfo,
+		StartedAt: p.startedAt,
+		Exit:      *p.exit,
+		Peak:      p.series.peakMetrics(),
+	}, nil
+}
+
+// Get all finished watches (without metrics), sorted by pid
+func (s *Stat) GetFinishedWatches() ([]WatchHistory, error) {
+	pids := make(map[int32]struct{}, 0)
+
+	// Finished before a restart
+	records, err := s.storage.GetWatches()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Exit != nil && !record.Active {
+			pids[record.PID] = struct{}{}
+		}
+	}
+
+	s.watchedLock.Lock()
+
+	for pid := range s.finished {
+		pids[pid] = struct{}{}
+	}
+
+	s.watchedLock.Unlock()
+
+	finished := make([]WatchHistory, 0, len(pids))
+
+	for pid := range pids {
+		history, err := s.GetHistoryForPID(pid, 0)
+		if err != nil {
+			// Deleted in the meantime
+			continue
+		}
+
+		history.Metrics = nil
+		history.Buckets = nil
+		history.NextOffset = 0
+
+		finished = append(finished, history)
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].PID < finished[j].PID })
+
+	return finished, nil
+}
+
+// Delete the history of a finished watch (incl. stored metrics)
+func (s *Stat) DeleteHistoryForPID(pid int32) error {
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	_, ok := s.finished[pid]
+
+	delete(s.finished, pid)
+
+	record, err := s.storage.GetWatch(pid)
+	if err != nil || record.Exit == nil {
+		if !ok {
+			return NoHistoryErr
+		}
+
+		return nil
+	}
+
+	// A new watch has been started for the pid in the meantime
+	if record.Active {
+		return nil
+	}
+
+	if err := s.storage.DeleteWatch(pid); err != nil {
+		return fmt.Errorf("unable to delete stored watch for pid '%v': %v", pid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package stat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryPeakSurvivesRestart(t *testing.T) {
+	// Storage + series only keep the 2 newest samples; the peak is evicted
+	storage := NewMemoryStorage(2)
+	policy := RetentionPolicy{MaxSamples: 2}
+
+	const pid = 4242
+
+	if err := storage.SaveWatch(WatchRecord{PID: pid, Config: WatchConfig{Retention: policy}, StartedAt: seriesStart, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(storage, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watchedProc := &Proc{
+		ProcInfo: ProcInfo{PID: pid},
+		series:   newSeries(policy),
+		exit:     &ExitInfo{ExitedAt: time.Now(), Reason: ExitReasonExited},
+	}
+
+	for _, rss := range []uint64{1, 9, 3, 2} {
+		m := ProcInfoMetrics{RSS: rss, Timestamp: seriesStart.Add(time.Duration(rss) * time.Second)}
+
+		watchedProc.series.append(m)
+		storage.AppendMetrics(pid, m)
+	}
+
+	s.watchedLock.Lock()
+	s.watched[pid] = watchedProc
+	s.watchedLock.Unlock()
+
+	s.finishWatch(watchedProc)
+
+	live, err := s.GetHistoryForPID(pid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restarted pidstat only has what is stored
+	restarted, err := New(storage, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := restarted.GetHistoryForPID(pid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if live.Peak.RSS != 9 || stored.Peak.RSS != 9 {
+		t.Errorf("expected peak rss 9 before and after restart, got %v and %v", live.Peak.RSS, stored.Peak.RSS)
+	}
+}
@@ -0,0 +1,159 @@
+package stat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// Max time a command hook / webhook gets to handle a single alert
+	NotifyTimeout = 30 * time.Second
+
+	// Max alerts queued per notifier; alerts beyond that are dropped
+	NotifyQueueSize = 100
+)
+
+// Notifier delivers alerts whenever they fire or resolve
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// notifyWorker delivers alerts to a single notifier one at a time, in the
+// order they were queued; a slow notifier does not hold up the others
+type notifyWorker struct {
+	notifier Notifier
+	queue    chan Alert
+}
+
+func newNotifyWorkers(notifiers []Notifier) []*notifyWorker {
+	workers := make([]*notifyWorker, 0, len(notifiers))
+
+	for _, notifier := range notifiers {
+		w := &notifyWorker{
+			notifier: notifier,
+			queue:    make(chan Alert, NotifyQueueSize),
+		}
+
+		go w.run()
+
+		workers = append(workers, w)
+	}
+
+	return workers
+}
+
+func (w *notifyWorker) run() {
+	for alert := range w.queue {
+		if err := w.notifier.Notify(alert); err != nil {
+			sugar.Errorf("unable to deliver alert '%v' via %T: %v", alert.ID, w.notifier, err)
+		}
+	}
+}
+
+// Queue an alert; never blocks
+func (w *notifyWorker) send(alert Alert) {
+	select {
+	case w.queue <- alert:
+	default:
+		sugar.Errorf("dropping alert '%v' for %T: too many undelivered alerts", alert.ID, w.notifier)
+	}
+}
+
+// Deliver whatever is still queued, then exit
+func (w *notifyWorker) stop() {
+	close(w.queue)
+}
+
+// LogNotifier writes alerts to the log; always enabled
+type LogNotifier struct{}
+
+func (l *LogNotifier) Notify(alert Alert) error {
+	if alert.State == AlertFiring {
+		sugar.Warnf("alert '%v' firing: %v", alert.ID, alert.Message)
+	} else {
+		sugar.Infof("alert '%v' %v", alert.ID, alert.Message)
+	}
+
+	return nil
+}
+
+// CommandNotifier runs a local command (via 'sh -c') for every alert. The
+// alert is passed as JSON on stdin and as PIDSTAT_ALERT_* env vars.
+type CommandNotifier struct {
+	Command string
+}
+
+func NewCommandNotifier(command string) *CommandNotifier {
+	return &CommandNotifier{
+		Command: command,
+	}
+}
+
+func (c *CommandNotifier) Notify(alert Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), NotifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"PIDSTAT_ALERT_ID="+alert.ID,
+		"PIDSTAT_ALERT_RULE="+alert.Rule,
+		"PIDSTAT_ALERT_STATE="+alert.State,
+		fmt.Sprintf("PIDSTAT_ALERT_PID=%d", alert.PID),
+		"PIDSTAT_ALERT_NAME="+alert.Name,
+		"PIDSTAT_ALERT_METRIC="+alert.Metric,
+		fmt.Sprintf("PIDSTAT_ALERT_VALUE=%v", alert.Value),
+		"PIDSTAT_ALERT_MESSAGE="+alert.Message,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command failed: %v (output: %q)", err, output)
+	}
+
+	return nil
+}
+
+// WebhookNotifier POSTs every alert as JSON to a URL
+type WebhookNotifier struct {
+	URL string
+
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		client: &http.Client{Timeout: NotifyTimeout},
+	}
+}
+
+func (w *WebhookNotifier) Notify(alert Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned unexpected status '%v'", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package stat
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	alerts []Alert
+	lock   *sync.Mutex
+}
+
+func (r *recordingNotifier) Notify(alert Alert) error {
+	// Slow down the first delivery so that later alerts pile up
+	if alert.ID == "0" {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.alerts = append(r.alerts, alert)
+
+	return nil
+}
+
+func TestNotifyWorkerKeepsOrder(t *testing.T) {
+	notifier := &recordingNotifier{lock: &sync.Mutex{}}
+
+	workers := newNotifyWorkers([]Notifier{notifier})
+
+	states := []string{AlertFiring, AlertResolved, AlertFiring, AlertResolved}
+
+	for i, state := range states {
+		workers[0].send(Alert{ID: string(rune('0' + i)), State: state})
+	}
+
+	workers[0].stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		notifier.lock.Lock()
+		n := len(notifier.alerts)
+		notifier.lock.Unlock()
+
+		if n == len(states) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v alerts to be delivered, got %v", len(states), n)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i, alert := range notifier.alerts {
+		if alert.ID != string(rune('0'+i)) || alert.State != states[i] {
+			t.Errorf("alert %v delivered out of order: %+v", i, alert)
+		}
+	}
+}
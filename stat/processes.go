@@ -0,0 +1,421 @@
+package stat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	SortPID  = "pid"
+	SortName = "name"
+	SortCPU  = "cpu"
+	SortRSS  = "rss"
+)
+
+var (
+	InvalidCursorErr = errors.New("invalid cursor")
+)
+
+// ProcessFilter selects, orders and pages through the process list; the zero
+// value returns every process sorted by pid
+type ProcessFilter struct {
+	// Case-insensitive substrings (or regular expressions if Regex is set)
+	// that the name / cmd line must match
+	Name    string
+	CmdLine string
+	Regex   bool
+
+	// Only include processes owned by this user (name)
+	User string
+
+	// Only include watched processes
+	WatchedOnly bool
+
+	// One of SortPID (default), SortName, SortCPU or SortRSS; prefix with '-'
+	// for descending order. Ties are ordered by pid.
+	Sort string
+
+	// Return at most this many processes; 0 means no limit
+	Limit int
+
+	// NextCursor of the previous page
+	Cursor string
+}
+
+// ProcessPage is a single page of a (filtered) process list
+type ProcessPage struct {
+	Processes []ProcInfo `json:"processes"`
+
+	// Number of processes that matched the filter (across all pages)
+	Total int `json:"total"`
+
+	// Pass as Cursor to fetch the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Position of the last process of a page; the next page starts right after it
+type processCursor struct {
+	Sort string  `json:"s"`
+	PID  int32   `json:"p"`
+	Name string  `json:"n,omitempty"`
+	CPU  float64 `json:"c,omitempty"`
+	RSS  uint64  `json:"r,omitempty"`
+}
+
+// Validate the filter
+func (f ProcessFilter) Validate() error {
+	if _, _, err := f.order(); err != nil {
+		return err
+	}
+
+	if f.Limit < 0 {
+		return errors.New("'limit' cannot be negative")
+	}
+
+	if _, _, err := f.matchers(); err != nil {
+		return err
+	}
+
+	if _, err := f.cursor(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Sort key + direction
+func (f ProcessFilter) order() (string, bool, error) {
+	key := strings.TrimPrefix(f.Sort, "-")
+	descending := strings.HasPrefix(f.Sort, "-")
+
+	switch key {
+	case "":
+		return SortPID, descending, nil
+	case SortPID, SortName, SortCPU, SortRSS:
+		return key, descending, nil
+	}
+
+	return "", false, fmt.Errorf("invalid sort '%v' (expected one of pid, name, cpu or rss)", f.Sort)
+}
+
+// Name + cmd line matchers; nil means "match everything"
+func (f ProcessFilter) matchers() (func(string) bool, func(string) bool, error) {
+	name, err := f.matcher("name", f.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmdLine, err := f.matcher("cmdline", f.CmdLine)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return name, cmdLine, nil
+}
+
+func (f ProcessFilter) matcher(param, pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	if !f.Regex {
+		pattern = strings.ToLower(pattern)
+
+		return func(s string) bool { return strings.Contains(strings.ToLower(s), pattern) }, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%v' regex: %v", param, err)
+	}
+
+	return re.MatchString, nil
+}
+
+func (f ProcessFilter) cursor() (*processCursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, InvalidCursorErr
+	}
+
+	cursor := &processCursor{}
+
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, InvalidCursorErr
+	}
+
+	// Cursors are only meaningful for the sort order they were created with
+	if cursor.Sort != f.Sort {
+		return nil, InvalidCursorErr
+	}
+
+	return cursor, nil
+}
+
+func (f ProcessFilter) match(p ProcInfo, name, cmdLine func(string) bool) bool {
+	if f.WatchedOnly && !p.Watched {
+		return false
+	}
+
+	if f.User != "" && p.User != f.User {
+		return false
+	}
+
+	if name != nil && !name(p.Name) {
+		return false
+	}
+
+	if cmdLine != nil && !cmdLine(p.CmdLine) {
+		return false
+	}
+
+	return true
+}
+
+// Apply the filter to a process list; the list itself is left untouched
+func (f ProcessFilter) Apply(processes []ProcInfo) (ProcessPage, error) {
+	if err := f.Validate(); err != nil {
+		return ProcessPage{}, err
+	}
+
+	key, descending, _ := f.order()
+	name, cmdLine, _ := f.matchers()
+	cursor, _ := f.cursor()
+
+	matched := make([]ProcInfo, 0)
+
+	for _, p := range processes {
+		if f.match(p, name, cmdLine) {
+			matched = append(matched, p)
+		}
+	}
+
+	less := processLess(key, descending)
+
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	page := ProcessPage{
+		Processes: matched,
+		Total:     len(matched),
+	}
+
+	// Keyset pagination: skip everything up to (and including) the cursor
+	// position, so processes coming and going between pages do not shift the
+	// next page
+	if cursor != nil {
+		last := ProcInfo{PID: cursor.PID, Name: cursor.Name, CPU: cursor.CPU, RSS: cursor.RSS}
+
+		start := sort.Search(len(matched), func(i int) bool { return less(last, matched[i]) })
+
+		page.Processes = matched[start:]
+	}
+
+	if f.Limit > 0 && len(page.Processes) > f.Limit {
+		page.Processes = page.Processes[:f.Limit]
+
+		last := page.Processes[len(page.Processes)-1]
+
+		data, err := json.Marshal(processCursor{
+			Sort: f.Sort,
+			PID:  last.PID,
+			Name: last.Name,
+			CPU:  last.CPU,
+			RSS:  last.RSS,
+		})
+		if err != nil {
+			return ProcessPage{}, fmt.Errorf("unable to encode cursor: %v", err)
+		}
+
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	return page, nil
+}
+
+// Ordering for a sort key; ties are always broken by (ascending) pid
+func processLess(key string, descending bool) func(a, b ProcInfo) bool {
+	return func(a, b ProcInfo) bool {
+		var cmp int
+
+		switch key {
+		case SortName:
+			cmp = strings.Compare(a.Name, b.Name)
+		case SortCPU:
+			cmp = compareFloat(a.CPU, b.CPU)
+		case SortRSS:
+			cmp = compareFloat(float64(a.RSS), float64(b.RSS))
+		case SortPID:
+			cmp = compareFloat(float64(a.PID), float64(b.PID))
+		}
+
+		if descending {
+			cmp = -cmp
+		}
+
+		if cmp != 0 {
+			return cmp < 0
+		}
+
+		return a.PID < b.PID
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// Get a filtered, sorted page of the running processes (from cache)
+func (s *Stat) FindProcesses(filter ProcessFilter) (ProcessPage, error) {
+	processes, err := s.GetProcesses()
+	if err != nil {
+		return ProcessPage{}, err
+	}
+
+	return filter.Apply(processes)
+}
+
+// ProcessNode is a process (from the process list) with all of its children
+type ProcessNode struct {
+	ProcInfo
+
+	// Summed usage of the process + all of its descendants
+	TreeCPU float64 `json:"tree_cpu"`
+	TreeRSS uint64  `json:"tree_rss"`
+
+	// Number of (transitive) descendants
+	Descendants int `json:"descendants"`
+
+	// Ordered by pid
+	Children []ProcessNode `json:"children"`
+}
+
+// BuildProcessTree arranges a process list by ppid; processes whose parent is
+// not in the list (ie. init, kthreadd) are roots
+func BuildProcessTree(processes []ProcInfo) []ProcessNode {
+	byPID := make(map[int32]ProcInfo, len(processes))
+	children := make(map[int32][]int32, 0)
+
+	for _, p := range processes {
+		byPID[p.PID] = p
+	}
+
+	roots := make([]int32, 0)
+
+	for _, p := range processes {
+		if _, ok := byPID[p.PPID]; !ok || p.PPID == p.PID {
+			roots = append(roots, p.PID)
+			continue
+		}
+
+		children[p.PPID] = append(children[p.PPID], p.PID)
+	}
+
+	visited := make(map[int32]struct{}, len(processes))
+
+	var build func(pid int32) ProcessNode
+
+	build = func(pid int32) ProcessNode {
+		visited[pid] = struct{}{}
+
+		p := byPID[pid]
+
+		node := ProcessNode{
+			ProcInfo: p,
+			TreeCPU:  p.CPU,
+			TreeRSS:  p.RSS,
+			Children: make([]ProcessNode, 0),
+		}
+
+		childPIDs := children[pid]
+
+		sort.Slice(childPIDs, func(i, j int) bool { return childPIDs[i] < childPIDs[j] })
+
+		for _, childPID := range childPIDs {
+			// ppids are read one process at a time; a re-used pid could close a loop
+			if _, ok := visited[childPID]; ok {
+				continue
+			}
+
+			child := build(childPID)
+
+			node.TreeCPU += child.TreeCPU
+			node.TreeRSS += child.TreeRSS
+			node.Descendants += child.Descendants + 1
+			node.Children = append(node.Children, child)
+		}
+
+		return node
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+
+	tree := make([]ProcessNode, 0, len(roots))
+
+	for _, pid := range roots {
+		tree = append(tree, build(pid))
+	}
+
+	// Anything that was not reached is part of a loop; list it at the top
+	for _, p := range processes {
+		if _, ok := visited[p.PID]; !ok {
+			tree = append(tree, build(p.PID))
+		}
+	}
+
+	return tree
+}
+
+// ApplyTree applies the filter to a process tree: nodes that do not match are
+// dropped unless one of their descendants matches. Sort, limit and cursor do
+// not apply to trees. Subtree totals are left as they are.
+func (f ProcessFilter) ApplyTree(tree []ProcessNode) ([]ProcessNode, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	name, cmdLine, _ := f.matchers()
+
+	var prune func(nodes []ProcessNode) []ProcessNode
+
+	prune = func(nodes []ProcessNode) []ProcessNode {
+		kept := make([]ProcessNode, 0)
+
+		for _, node := range nodes {
+			node.Children = prune(node.Children)
+
+			if len(node.Children) > 0 || f.match(node.ProcInfo, name, cmdLine) {
+				kept = append(kept, node)
+			}
+		}
+
+		return kept
+	}
+
+	return prune(tree), nil
+}
+
+// Get the (cached) process list arranged as a tree
+func (s *Stat) GetProcessTree(filter ProcessFilter) ([]ProcessNode, error) {
+	processes, err := s.GetProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.ApplyTree(BuildProcessTree(processes))
+}
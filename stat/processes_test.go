@@ -0,0 +1,172 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func pidsOf(processes []ProcInfo) []int32 {
+	pids := make([]int32, 0, len(processes))
+
+	for _, p := range processes {
+		pids = append(pids, p.PID)
+	}
+
+	return pids
+}
+
+func TestProcessFilterCursorStability(t *testing.T) {
+	initial := []ProcInfo{
+		{PID: 1, Name: "init", RSS: 500},
+		{PID: 10, Name: "sshd", RSS: 300},
+		{PID: 20, Name: "bash", RSS: 300},
+		{PID: 30, Name: "nginx", RSS: 900},
+		{PID: 40, Name: "cron", RSS: 100},
+		{PID: 50, Name: "vim", RSS: 700},
+	}
+
+	without := func(pids ...int32) func([]ProcInfo) []ProcInfo {
+		return func(processes []ProcInfo) []ProcInfo {
+			kept := make([]ProcInfo, 0, len(processes))
+
+		outer:
+			for _, p := range processes {
+				for _, pid := range pids {
+					if p.PID == pid {
+						continue outer
+					}
+				}
+
+				kept = append(kept, p)
+			}
+
+			return kept
+		}
+	}
+
+	with := func(added ...ProcInfo) func([]ProcInfo) []ProcInfo {
+		return func(processes []ProcInfo) []ProcInfo {
+			return append(append([]ProcInfo{}, processes...), added...)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		sort      string
+		change    func([]ProcInfo) []ProcInfo
+		wantFirst []int32
+		wantNext  []int32
+	}{
+		{"unchanged", SortPID, nil, []int32{1, 10}, []int32{20, 30}},
+		{"last process of the page exited", SortPID, without(10), []int32{1, 10}, []int32{20, 30}},
+		{"process before the cursor exited", SortPID, without(1), []int32{1, 10}, []int32{20, 30}},
+		{"process started before the cursor", SortPID, with(ProcInfo{PID: 5, Name: "new"}), []int32{1, 10}, []int32{20, 30}},
+		{"process started after the cursor", SortPID, with(ProcInfo{PID: 15, Name: "new"}), []int32{1, 10}, []int32{15, 20}},
+		{"next process exited", SortPID, without(20), []int32{1, 10}, []int32{30, 40}},
+		{"descending rss", "-" + SortRSS, nil, []int32{30, 50}, []int32{1, 10}},
+		{"descending rss, tie broken by pid", "-" + SortRSS, with(ProcInfo{PID: 15, RSS: 300}), []int32{30, 50}, []int32{1, 10}},
+		{"descending rss, process exited at the cursor", "-" + SortRSS, without(50), []int32{30, 50}, []int32{1, 10}},
+		{"name", SortName, without(40), []int32{20, 40}, []int32{1, 30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := ProcessFilter{Sort: tt.sort, Limit: 2}
+
+			first, err := filter.Apply(initial)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := pidsOf(first.Processes); !reflect.DeepEqual(got, tt.wantFirst) {
+				t.Fatalf("first page = %v, want %v", got, tt.wantFirst)
+			}
+
+			if first.NextCursor == "" {
+				t.Fatal("expected a cursor for the next page")
+			}
+
+			processes := initial
+			if tt.change != nil {
+				processes = tt.change(initial)
+			}
+
+			filter.Cursor = first.NextCursor
+
+			next, err := filter.Apply(processes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := pidsOf(next.Processes); !reflect.DeepEqual(got, tt.wantNext) {
+				t.Errorf("next page = %v, want %v", got, tt.wantNext)
+			}
+
+			if next.Total != len(processes) {
+				t.Errorf("total = %v, want %v", next.Total, len(processes))
+			}
+		})
+	}
+}
+
+func TestProcessFilterLastPage(t *testing.T) {
+	processes := []ProcInfo{{PID: 1}, {PID: 2}, {PID: 3}}
+
+	tests := []struct {
+		name       string
+		limit      int
+		wantPages  [][]int32
+		wantCursor []bool
+	}{
+		{"no limit", 0, [][]int32{{1, 2, 3}}, []bool{false}},
+		{"limit larger than the list", 5, [][]int32{{1, 2, 3}}, []bool{false}},
+		{"limit equal to the list", 3, [][]int32{{1, 2, 3}}, []bool{false}},
+		{"several pages", 2, [][]int32{{1, 2}, {3}}, []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := ProcessFilter{Limit: tt.limit}
+
+			for i, want := range tt.wantPages {
+				page, err := filter.Apply(processes)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := pidsOf(page.Processes); !reflect.DeepEqual(got, want) {
+					t.Errorf("page %v = %v, want %v", i, got, want)
+				}
+
+				if (page.NextCursor != "") != tt.wantCursor[i] {
+					t.Errorf("page %v cursor = %q", i, page.NextCursor)
+				}
+
+				filter.Cursor = page.NextCursor
+			}
+		})
+	}
+}
+
+func TestProcessFilterInvalidCursor(t *testing.T) {
+	page, err := ProcessFilter{Sort: SortPID, Limit: 1}.Apply([]ProcInfo{{PID: 1}, {PID: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter ProcessFilter
+	}{
+		{"garbage", ProcessFilter{Cursor: "not a cursor"}},
+		{"different sort order", ProcessFilter{Sort: SortName, Cursor: page.NextCursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.filter.Apply([]ProcInfo{{PID: 1}}); err != InvalidCursorErr {
+				t.Errorf("error = %v, want InvalidCursorErr", err)
+			}
+		})
+	}
+}
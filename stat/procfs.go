@@ -0,0 +1,284 @@
+package stat
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Things gopsutil does not expose (or only one file read per value) are read
+// straight from procfs
+
+var (
+	pageSize = uint64(os.Getpagesize())
+
+	// Boot time only has to be looked up once (see getBootTime())
+	bootTimeLock = &sync.Mutex{}
+	bootTimeSecs uint64
+)
+
+// Boot time (seconds since epoch); start times in /proc/<pid>/stat are relative to it.
+// Failed lookups are not cached, the next call tries again.
+func getBootTime() (uint64, error) {
+	bootTimeLock.Lock()
+	defer bootTimeLock.Unlock()
+
+	if bootTimeSecs != 0 {
+		return bootTimeSecs, nil
+	}
+
+	bootTime, err := host.BootTime()
+	if err != nil {
+		return 0, err
+	}
+
+	bootTimeSecs = bootTime
+
+	return bootTimeSecs, nil
+}
+
+// procStat contains the fields of /proc/<pid>/stat we care about
+type procStat struct {
+	Comm  string
+	State string
+	PPID  int32
+
+	// Cumulative page faults
+	MinFlt uint64
+	MajFlt uint64
+
+	// Cumulative CPU time (seconds)
+	UTime float64
+	STime float64
+
+	StartTime time.Time
+
+	// Resident set size (bytes)
+	RSS uint64
+
+	// Wait status; only meaningful for zombies (-1 if not available)
+	ExitStatus int
+}
+
+// Path below procfs; honours HOST_PROC the same way gopsutil does
+func procPath(elem ...string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// Parse /proc/<pid>/stat
+func readProcStat(pid int32) (procStat, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	return parseProcStat(string(data))
+}
+
+// Parse /proc/<pid>/task/<tid>/stat (same format, but for a single thread)
+func readTaskStat(pid, tid int32) (procStat, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "task", strconv.Itoa(int(tid)), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	return parseProcStat(string(data))
+}
+
+func parseProcStat(data string) (procStat, error) {
+	// comm is enclosed in parens and may itself contain spaces + parens
+	start := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+
+	if start < 0 || end < start {
+		return procStat{}, errors.New("unexpected stat format")
+	}
+
+	// fields[0] is field 3 (state) in proc(5)
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, errors.New("unexpected stat format")
+	}
+
+	ppid, err := strconv.ParseInt(fields[1], 10, 32)
+	if err != nil {
+		return procStat{}, fmt.Errorf("invalid ppid: %v", err)
+	}
+
+	var faults [2]uint64
+
+	for i, field := range []string{fields[7], fields[9]} {
+		if faults[i], err = strconv.ParseUint(field, 10, 64); err != nil {
+			return procStat{}, fmt.Errorf("invalid page faults: %v", err)
+		}
+	}
+
+	var ticks [3]uint64
+
+	for i, field := range []string{fields[11], fields[12], fields[19]} {
+		if ticks[i], err = strconv.ParseUint(field, 10, 64); err != nil {
+			return procStat{}, fmt.Errorf("invalid cpu/start time: %v", err)
+		}
+	}
+
+	rss, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return procStat{}, fmt.Errorf("invalid rss: %v", err)
+	}
+
+	if rss < 0 {
+		rss = 0
+	}
+
+	// exit_code (field 52) is only available since Linux 3.5
+	exitStatus := -1
+
+	if len(fields) > 49 {
+		if status, err := strconv.Atoi(fields[49]); err == nil {
+			exitStatus = status
+		}
+	}
+
+	bootTime, err := getBootTime()
+	if err != nil {
+		return procStat{}, fmt.Errorf("unable to determine boot time: %v", err)
+	}
+
+	startedAfterBoot := time.Duration(float64(ticks[2]) / process.ClockTicks * float64(time.Second))
+
+	return procStat{
+		Comm:       data[start+1 : end],
+		State:      fields[0],
+		PPID:       int32(ppid),
+		MinFlt:     faults[0],
+		MajFlt:     faults[1],
+		UTime:      float64(ticks[0]) / process.ClockTicks,
+		STime:      float64(ticks[1]) / process.ClockTicks,
+		StartTime:  time.Unix(int64(bootTime), 0).Add(startedAfterBoot),
+		RSS:        uint64(rss) * pageSize,
+		ExitStatus: exitStatus,
+	}, nil
+}
+
+// Time (seconds) the process spent waiting on a run queue, from
+// /proc/<pid>/schedstat; only available if the kernel has schedstats
+func readRunQueueWait(pid int32) (float64, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "schedstat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// <time on cpu (ns)> <time waiting on a run queue (ns)> <# of timeslices>
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, errors.New("unexpected schedstat format")
+	}
+
+	wait, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid run queue wait: %v", err)
+	}
+
+	return float64(wait) / 1e9, nil
+}
+
+// smapsTotals contains the totals of /proc/<pid>/smaps(_rollup) we care about
+// (bytes)
+type smapsTotals struct {
+	PSS          uint64
+	SharedClean  uint64
+	SharedDirty  uint64
+	PrivateClean uint64
+	PrivateDirty uint64
+	Anonymous    uint64
+}
+
+// Read /proc/<pid>/smaps_rollup, falling back to summing up every mapping in
+// /proc/<pid>/smaps on kernels older than 4.14. Both are only readable by the
+// process owner (or root).
+func readSmaps(pid int32) (smapsTotals, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "smaps_rollup"))
+	if os.IsNotExist(err) {
+		data, err = ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "smaps"))
+	}
+
+	if err != nil {
+		return smapsTotals{}, err
+	}
+
+	return parseSmaps(string(data)), nil
+}
+
+func parseSmaps(data string) smapsTotals {
+	totals := smapsTotals{}
+
+	fields := map[string]*uint64{
+		"Pss:":           &totals.PSS,
+		"Shared_Clean:":  &totals.SharedClean,
+		"Shared_Dirty:":  &totals.SharedDirty,
+		"Private_Clean:": &totals.PrivateClean,
+		"Private_Dirty:": &totals.PrivateDirty,
+		"Anonymous:":     &totals.Anonymous,
+	}
+
+	// ie. "Pss:                 374 kB"; mapping headers are skipped
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 3 || parts[2] != "kB" {
+			continue
+		}
+
+		total, ok := fields[parts[0]]
+		if !ok {
+			continue
+		}
+
+		if kb, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			*total += kb * 1024
+		}
+	}
+
+	return totals
+}
+
+// Read /proc/<pid>/cmdline; args are joined by spaces (like gopsutil does)
+func readCmdLine(pid int32) (string, error) {
+	data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "cmdline"))
+	if err != nil {
+		return "", err
+	}
+
+	args := strings.FieldsFunc(string(data), func(r rune) bool { return r == 0 })
+
+	return strings.Join(args, " "), nil
+}
+
+// Uid owning /proc/<pid> (the effective uid of the process)
+func procOwner(pid int32) (uint32, error) {
+	fi, err := os.Stat(procPath(strconv.Itoa(int(pid))))
+	if err != nil {
+		return 0, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.New("unable to determine owner")
+	}
+
+	return st.Uid, nil
+}
@@ -0,0 +1,165 @@
+package stat
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Query selects samples by time instead of by offset
+type Query struct {
+	// Only include samples at or after / at or before these times (zero means unbounded)
+	From time.Time
+	To   time.Time
+
+	// Resample to one (averaged) sample per step; 0 disables resampling
+	Step time.Duration
+
+	// Return at most this many (most recent) samples; 0 means no limit
+	Limit int
+}
+
+// Validate the query
+func (q Query) Validate() error {
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return errors.New("'to' must not be before 'from'")
+	}
+
+	if q.Step < 0 {
+		return errors.New("'step' cannot be negative")
+	}
+
+	if q.Limit < 0 {
+		return errors.New("'limit' cannot be negative")
+	}
+
+	return nil
+}
+
+// Get metrics for a (current or stored) watch selected by time. Periods that
+// have already been downsampled are represented by their bucket averages.
+func (s *Stat) QueryStatsForPID(pid int32, query Query) (ProcInfo, error) {
+	if err := query.Validate(); err != nil {
+		return ProcInfo{}, err
+	}
+
+	// Offset 0 returns everything that is retained (raw samples + buckets)
+	procInfo, err := s.GetStatsForPID(pid, 0)
+	if err != nil {
+		return ProcInfo{}, err
+	}
+
+	return query.Apply(procInfo), nil
+}
+
+// Select the samples of a full (offset 0) read by time; buckets are flattened
+// into their averages
+func (q Query) Apply(procInfo ProcInfo) ProcInfo {
+	procInfo.Metrics = q.apply(flatten(procInfo.Buckets, procInfo.Metrics))
+	procInfo.Buckets = nil
+
+	if procInfo.TreeMetrics != nil {
+		procInfo.TreeMetrics = q.apply(flatten(procInfo.TreeBuckets, procInfo.TreeMetrics))
+		procInfo.TreeBuckets = nil
+	}
+
+	if procInfo.Children != nil {
+		children := make([]ProcInfo, 0, len(procInfo.Children))
+
+		for _, child := range procInfo.Children {
+			children = append(children, q.Apply(child))
+		}
+
+		procInfo.Children = children
+	}
+
+	return procInfo
+}
+
+// Bucket averages followed by the raw samples
+func flatten(buckets []MetricsBucket, metrics []ProcInfoMetrics) []ProcInfoMetrics {
+	flattened := make([]ProcInfoMetrics, 0, len(buckets)+len(metrics))
+
+	for _, bucket := range buckets {
+		flattened = append(flattened, bucket.Avg)
+	}
+
+	return append(flattened, metrics...)
+}
+
+// Filter by time, resample + limit
+func (q Query) apply(metrics []ProcInfoMetrics) []ProcInfoMetrics {
+	selected := make([]ProcInfoMetrics, 0, len(metrics))
+
+	for _, m := range metrics {
+		if !q.From.IsZero() && m.Timestamp.Before(q.From) {
+			continue
+		}
+
+		if !q.To.IsZero() && m.Timestamp.After(q.To) {
+			continue
+		}
+
+		selected = append(selected, m)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Timestamp.Before(selected[j].Timestamp) })
+
+	if q.Step > 0 {
+		selected = resample(selected, q.Step, q.From)
+	}
+
+	if q.Limit > 0 && len(selected) > q.Limit {
+		selected = selected[len(selected)-q.Limit:]
+	}
+
+	return selected
+}
+
+// Average samples into consecutive 'step' wide windows; windows are aligned
+// to 'origin' (or to the first sample if origin is not set) and every
+// resampled sample is timestamped with the start of its window. Empty windows
+// are skipped.
+func resample(metrics []ProcInfoMetrics, step time.Duration, origin time.Time) []ProcInfoMetrics {
+	if len(metrics) == 0 {
+		return metrics
+	}
+
+	if origin.IsZero() {
+		origin = metrics[0].Timestamp
+	}
+
+	resampled := make([]ProcInfoMetrics, 0)
+
+	var (
+		window      []ProcInfoMetrics
+		windowStart time.Time
+	)
+
+	flush := func() {
+		if len(window) == 0 {
+			return
+		}
+
+		m := newBucket(window).Avg
+		m.Timestamp = windowStart
+
+		resampled = append(resampled, m)
+		window = window[:0]
+	}
+
+	for _, m := range metrics {
+		start := origin.Add(m.Timestamp.Sub(origin) / step * step)
+
+		if len(window) > 0 && !start.Equal(windowStart) {
+			flush()
+		}
+
+		windowStart = start
+		window = append(window, m)
+	}
+
+	flush()
+
+	return resampled
+}
@@ -0,0 +1,136 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Seconds after seriesStart of every sample
+func secondsOf(metrics []ProcInfoMetrics) []float64 {
+	seconds := make([]float64, 0, len(metrics))
+
+	for _, m := range metrics {
+		seconds = append(seconds, m.Timestamp.Sub(seriesStart).Seconds())
+	}
+
+	return seconds
+}
+
+func at(seconds float64) time.Time {
+	return seriesStart.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func TestQueryApply(t *testing.T) {
+	// 0-5 are folded into 3 buckets (reported at 0.5s, 2.5s and 4.5s), 6-9 stay raw
+	s := newTestSeries(RetentionPolicy{MaxSamples: 4, BucketSize: 2}, 10)
+
+	metrics, buckets, _, err := s.read(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	procInfo := ProcInfo{PID: 1, Metrics: metrics, Buckets: buckets}
+
+	// Tree sums + children go through the same selection
+	procInfo.TreeMetrics = metrics
+	procInfo.TreeBuckets = buckets
+	procInfo.Children = []ProcInfo{{PID: 2, Metrics: metrics, Buckets: buckets}}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []float64
+	}{
+		{"buckets are flattened", Query{}, []float64{0.5, 2.5, 4.5, 6, 7, 8, 9}},
+		{"from", Query{From: at(4)}, []float64{4.5, 6, 7, 8, 9}},
+		{"to", Query{To: at(6)}, []float64{0.5, 2.5, 4.5, 6}},
+		{"from + to are inclusive", Query{From: at(2.5), To: at(7)}, []float64{2.5, 4.5, 6, 7}},
+		{"empty window", Query{From: at(20)}, []float64{}},
+		{"window between samples", Query{From: at(7.2), To: at(7.8)}, []float64{}},
+		{"limit keeps the most recent", Query{Limit: 3}, []float64{7, 8, 9}},
+		{"limit larger than the result", Query{Limit: 20}, []float64{0.5, 2.5, 4.5, 6, 7, 8, 9}},
+		{"step aligned to the first sample", Query{Step: 4 * time.Second}, []float64{0.5, 4.5, 8.5}},
+		{"step aligned to from", Query{From: at(0), Step: 4 * time.Second}, []float64{0, 4, 8}},
+		{"empty steps are skipped", Query{From: at(0), Step: time.Second}, []float64{0, 2, 4, 6, 7, 8, 9}},
+		{"limit applies after resampling", Query{From: at(0), Step: 4 * time.Second, Limit: 2}, []float64{4, 8}},
+		{"step on an empty window", Query{From: at(20), Step: time.Second}, []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.query.Apply(procInfo)
+
+			if seconds := secondsOf(got.Metrics); !reflect.DeepEqual(seconds, tt.want) {
+				t.Errorf("samples at %v, want %v", seconds, tt.want)
+			}
+
+			if seconds := secondsOf(got.TreeMetrics); !reflect.DeepEqual(seconds, tt.want) {
+				t.Errorf("tree samples at %v, want %v", seconds, tt.want)
+			}
+
+			if seconds := secondsOf(got.Children[0].Metrics); !reflect.DeepEqual(seconds, tt.want) {
+				t.Errorf("child samples at %v, want %v", seconds, tt.want)
+			}
+
+			if got.Buckets != nil || got.TreeBuckets != nil || got.Children[0].Buckets != nil {
+				t.Error("expected buckets to be flattened")
+			}
+		})
+	}
+}
+
+func TestResampleAverages(t *testing.T) {
+	metrics := make([]ProcInfoMetrics, 0)
+
+	for i, cpu := range []float64{10, 20, 30, 40, 50} {
+		metrics = append(metrics, ProcInfoMetrics{CPU: cpu, Timestamp: at(float64(i))})
+	}
+
+	tests := []struct {
+		name string
+		step time.Duration
+		want []float64
+	}{
+		{"step smaller than the interval", 500 * time.Millisecond, []float64{10, 20, 30, 40, 50}},
+		{"two samples per step", 2 * time.Second, []float64{15, 35, 50}},
+		{"one step", time.Minute, []float64{30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]float64, 0)
+
+			for _, m := range resample(metrics, tt.step, time.Time{}) {
+				got = append(got, m.CPU)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cpu = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   Query
+		wantErr bool
+	}{
+		{"empty", Query{}, false},
+		{"from + to", Query{From: at(0), To: at(1)}, false},
+		{"from equals to", Query{From: at(1), To: at(1)}, false},
+		{"to before from", Query{From: at(1), To: at(0)}, true},
+		{"negative step", Query{Step: -time.Second}, true},
+		{"negative limit", Query{Limit: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
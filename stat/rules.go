@@ -0,0 +1,293 @@
+package stat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+var (
+	RuleNotFoundErr = errors.New("rule does not exist")
+	RuleExistsErr   = errors.New("rule already exists")
+)
+
+// WatchRule automatically starts watches for every process that matches it.
+// Rules are evaluated each time the process list is refreshed, so processes
+// that get restarted (with a new PID) are picked up again automatically.
+//
+// All of the set matchers must match; at least one matcher must be set.
+type WatchRule struct {
+	// Generated if not set
+	ID string `json:"id"`
+
+	// Glob (see filepath.Match) matched against the process name
+	Name string `json:"name,omitempty"`
+
+	// Regular expression matched against the full cmdline
+	CmdLine string `json:"cmd_line,omitempty"`
+
+	// Name of the user owning the process
+	User string `json:"user,omitempty"`
+
+	// Glob (see filepath.Match) matched against the full path of the executable
+	Exe string `json:"exe,omitempty"`
+
+	// Config used for watches started by this rule
+	Config WatchConfig `json:"config"`
+
+	cmdLineRegex *regexp.Regexp
+}
+
+// Validate the rule + compile its matchers
+func (r *WatchRule) Validate() error {
+	if r.Name == "" && r.CmdLine == "" && r.User == "" && r.Exe == "" {
+		return errors.New("at least one of 'name', 'cmd_line', 'user' or 'exe' must be set")
+	}
+
+	if _, err := filepath.Match(r.Name, ""); err != nil {
+		return fmt.Errorf("invalid name glob: %v", err)
+	}
+
+	if _, err := filepath.Match(r.Exe, ""); err != nil {
+		return fmt.Errorf("invalid exe glob: %v", err)
+	}
+
+	if err := r.Config.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+
+	if r.CmdLine != "" {
+		regex, err := regexp.Compile(r.CmdLine)
+		if err != nil {
+			return fmt.Errorf("invalid cmd_line regex: %v", err)
+		}
+
+		r.cmdLineRegex = regex
+	}
+
+	return nil
+}
+
+// Cheap checks first; user + exe require extra syscalls so are only looked up
+// if everything else matched
+func (r *WatchRule) matches(procInfo ProcInfo) bool {
+	if r.Name != "" {
+		if ok, _ := filepath.Match(r.Name, procInfo.Name); !ok {
+			return false
+		}
+	}
+
+	if r.cmdLineRegex != nil && !r.cmdLineRegex.MatchString(procInfo.CmdLine) {
+		return false
+	}
+
+	if r.User == "" && r.Exe == "" {
+		return true
+	}
+
+	proc, err := process.NewProcess(procInfo.PID)
+	if err != nil {
+		return false
+	}
+
+	if r.User != "" {
+		user, err := proc.Username()
+		if err != nil || user != r.User {
+			return false
+		}
+	}
+
+	if r.Exe != "" {
+		exe, err := proc.Exe()
+		if err != nil {
+			return false
+		}
+
+		if ok, _ := filepath.Match(r.Exe, exe); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func generateID() (string, error) {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Stat) loadRules() error {
+	rules, err := s.storage.GetRules()
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		rule := rule
+
+		if err := rule.Validate(); err != nil {
+			sugar.Warnf("ignoring invalid stored rule '%v': %v", rule.ID, err)
+			continue
+		}
+
+		s.rules[rule.ID] = &rule
+	}
+
+	return nil
+}
+
+// Caller must hold rulesLock
+func (s *Stat) sortedRules() []WatchRule {
+	rules := make([]WatchRule, 0, len(s.rules))
+
+	for _, rule := range s.rules {
+		rules = append(rules, *rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	return rules
+}
+
+// Get all watch rules
+func (s *Stat) GetRules() ([]WatchRule, error) {
+	s.rulesLock.Lock()
+	defer s.rulesLock.Unlock()
+
+	return s.sortedRules(), nil
+}
+
+// Add a watch rule; it is applied to the current process list right away
+func (s *Stat) AddRule(rule WatchRule) (WatchRule, error) {
+	if err := rule.Validate(); err != nil {
+		return WatchRule{}, err
+	}
+
+	if rule.ID == "" {
+		id, err := generateID()
+		if err != nil {
+			return WatchRule{}, fmt.Errorf("unable to generate rule id: %v", err)
+		}
+
+		rule.ID = id
+	}
+
+	s.rulesLock.Lock()
+
+	if _, ok := s.rules[rule.ID]; ok {
+		s.rulesLock.Unlock()
+		return WatchRule{}, RuleExistsErr
+	}
+
+	s.rules[rule.ID] = &rule
+
+	if err := s.storage.SaveRules(s.sortedRules()); err != nil {
+		delete(s.rules, rule.ID)
+		s.rulesLock.Unlock()
+
+		return WatchRule{}, fmt.Errorf("unable to persist rule: %v", err)
+	}
+
+	s.rulesLock.Unlock()
+
+	s.processListLock.Lock()
+	processList := make([]ProcInfo, len(s.processList))
+	copy(processList, s.processList)
+	s.processListLock.Unlock()
+
+	s.applyRules(processList)
+
+	return rule, nil
+}
+
+// Delete a watch rule; watches that were started by it keep running
+func (s *Stat) DeleteRule(id string) error {
+	s.rulesLock.Lock()
+	defer s.rulesLock.Unlock()
+
+	rule, ok := s.rules[id]
+	if !ok {
+		return RuleNotFoundErr
+	}
+
+	delete(s.rules, id)
+
+	if err := s.storage.SaveRules(s.sortedRules()); err != nil {
+		s.rules[id] = rule
+		return fmt.Errorf("unable to persist rules: %v", err)
+	}
+
+	return nil
+}
+
+// Start watches for all unwatched processes that match a rule
+func (s *Stat) applyRules(processList []ProcInfo) {
+	// Snapshot rules so that starting watches does not happen under rulesLock
+	s.rulesLock.Lock()
+
+	if len(s.rules) == 0 {
+		s.rulesLock.Unlock()
+		return
+	}
+
+	rules := s.sortedRules()
+
+	// Forget about manually stopped pids that are gone
+	running := make(map[int32]struct{}, len(processList))
+
+	for _, p := range processList {
+		running[p.PID] = struct{}{}
+	}
+
+	for pid := range s.ruleIgnored {
+		if _, ok := running[pid]; !ok {
+			delete(s.ruleIgnored, pid)
+		}
+	}
+
+	ignored := make(map[int32]struct{}, len(s.ruleIgnored))
+
+	for pid := range s.ruleIgnored {
+		ignored[pid] = struct{}{}
+	}
+
+	s.rulesLock.Unlock()
+
+	for _, procInfo := range processList {
+		if _, ok := ignored[procInfo.PID]; ok {
+			continue
+		}
+
+		if s.isWatched(procInfo.PID) {
+			continue
+		}
+
+		for _, rule := range rules {
+			if !rule.matches(procInfo) {
+				continue
+			}
+
+			procInfo.Rule = rule.ID
+
+			if err := s.newWatch(procInfo, rule.Config); err != nil {
+				sugar.Errorf("unable to start watch for pid '%v' (rule '%v'): %v", procInfo.PID, rule.ID, err)
+			} else {
+				sugar.Infof("started watch for pid '%v' (rule '%v')", procInfo.PID, rule.ID)
+			}
+
+			// First matching rule wins
+			break
+		}
+	}
+}
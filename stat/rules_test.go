@@ -0,0 +1,58 @@
+package stat
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRuleDoesNotReattachAfterError(t *testing.T) {
+	s, err := New(nil, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid := int32(os.Getpid())
+	procInfo := ProcInfo{PID: pid, Name: "pidstat-rules-test", Rule: "rule"}
+
+	// Rule-started watch that could no longer collect metrics
+	watchedProc := &Proc{
+		ProcInfo: procInfo,
+		series:   newSeries(RetentionPolicy{}),
+		exit:     &ExitInfo{ExitedAt: time.Now(), Reason: ExitReasonError, Error: "permission denied"},
+	}
+
+	s.watchedLock.Lock()
+	s.watched[pid] = watchedProc
+	s.watchedLock.Unlock()
+
+	s.finishWatch(watchedProc)
+
+	rule := WatchRule{ID: "rule", Name: procInfo.Name}
+
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.rulesLock.Lock()
+	s.rules[rule.ID] = &rule
+	s.rulesLock.Unlock()
+
+	s.applyRules([]ProcInfo{procInfo})
+
+	if s.isWatched(pid) {
+		t.Fatal("rule re-attached to a pid whose watch failed")
+	}
+
+	// Pid is forgotten once it is gone, so a new process with the same pid is
+	// picked up again
+	s.applyRules([]ProcInfo{})
+
+	s.rulesLock.Lock()
+	_, ignored := s.ruleIgnored[pid]
+	s.rulesLock.Unlock()
+
+	if ignored {
+		t.Error("expected pid to no longer be ignored once it is gone")
+	}
+}
@@ -0,0 +1,408 @@
+package stat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// 1 hour worth of raw samples at the default StatInterval
+	DefaultMaxSamples = 720
+
+	// Fold every 12 evicted raw samples (1 minute at default StatInterval) into a bucket
+	DefaultBucketSize = 12
+
+	// 1 week worth of 1 minute buckets
+	DefaultMaxBuckets = 10080
+)
+
+// Duration is a time.Duration that (un)marshals to/from JSON as a string ("5s", "24h")
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration '%v'", string(data))
+	}
+
+	return nil
+}
+
+// RetentionPolicy determines how many samples are kept for a watch.
+//
+// The most recent MaxSamples raw samples are kept as-is; once a raw sample is
+// evicted it is folded (together with BucketSize-1 other evicted samples) into
+// a min/max/avg bucket. At most MaxBuckets buckets are kept. Anything older
+// than MaxAge (if set) is dropped altogether.
+type RetentionPolicy struct {
+	MaxSamples int      `json:"max_samples"`
+	MaxAge     Duration `json:"max_age"`
+	BucketSize int      `json:"bucket_size"`
+	MaxBuckets int      `json:"max_buckets"`
+}
+
+// MetricsBucket is a downsampled summary of BucketSize consecutive samples
+type MetricsBucket struct {
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Samples int             `json:"samples"`
+	Min     ProcInfoMetrics `json:"min"`
+	Max     ProcInfoMetrics `json:"max"`
+	Avg     ProcInfoMetrics `json:"avg"`
+}
+
+// Fill in defaults for any unset fields
+func (r RetentionPolicy) withDefaults() RetentionPolicy {
+	if r.MaxSamples <= 0 {
+		r.MaxSamples = DefaultMaxSamples
+	}
+
+	if r.BucketSize <= 0 {
+		r.BucketSize = DefaultBucketSize
+	}
+
+	if r.MaxBuckets <= 0 {
+		r.MaxBuckets = DefaultMaxBuckets
+	}
+
+	return r
+}
+
+// series holds the samples for a single watch; memory use is bounded by its
+// retention policy.
+type series struct {
+	policy RetentionPolicy
+
+	// Ring buffer of raw samples; oldest sample is at samples[head]. Grows
+	// lazily up to MaxSamples entries.
+	samples []ProcInfoMetrics
+	head    int
+	count   int
+
+	// Total number of samples ever appended; used to turn offsets into ring positions
+	total int
+
+	// Highest value of every metric ever appended (incl. evicted samples)
+	peak ProcInfoMetrics
+
+	// Evicted raw samples that have not been folded into a bucket yet
+	pending []ProcInfoMetrics
+
+	// Ring buffer of downsampled buckets; oldest bucket is at buckets[bucketHead].
+	// Grows lazily up to MaxBuckets entries.
+	buckets     []MetricsBucket
+	bucketHead  int
+	bucketCount int
+
+	lock *sync.Mutex
+}
+
+func newSeries(policy RetentionPolicy) *series {
+	policy = policy.withDefaults()
+
+	return &series{
+		policy:  policy,
+		samples: make([]ProcInfoMetrics, 0),
+		pending: make([]ProcInfoMetrics, 0, policy.BucketSize),
+		buckets: make([]MetricsBucket, 0),
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (s *series) append(m ProcInfoMetrics) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Ring is full - evict oldest raw sample into a bucket
+	if s.count == s.policy.MaxSamples {
+		s.downsample(s.samples[s.head])
+
+		s.head = (s.head + 1) % len(s.samples)
+		s.count--
+	}
+
+	// Until the ring reaches its max size, samples are contiguous and can be appended
+	if len(s.samples) < s.policy.MaxSamples {
+		s.samples = append(s.samples, m)
+	} else {
+		s.samples[(s.head+s.count)%len(s.samples)] = m
+	}
+
+	if s.total == 0 {
+		s.peak = m
+	} else {
+		s.peak = maxMetrics(s.peak, m)
+	}
+
+	s.count++
+	s.total++
+
+	s.expire(m.Timestamp)
+}
+
+func (s *series) downsample(m ProcInfoMetrics) {
+	s.pending = append(s.pending, m)
+
+	if len(s.pending) < s.policy.BucketSize {
+		return
+	}
+
+	// Bucket ring is full - drop oldest bucket
+	if s.bucketCount == s.policy.MaxBuckets {
+		s.bucketHead = (s.bucketHead + 1) % len(s.buckets)
+		s.bucketCount--
+	}
+
+	if len(s.buckets) < s.policy.MaxBuckets {
+		s.buckets = append(s.buckets, newBucket(s.pending))
+	} else {
+		s.buckets[(s.bucketHead+s.bucketCount)%len(s.buckets)] = newBucket(s.pending)
+	}
+
+	s.bucketCount++
+
+	s.pending = s.pending[:0]
+}
+
+// Drop anything older than MaxAge
+func (s *series) expire(now time.Time) {
+	if s.policy.MaxAge <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-time.Duration(s.policy.MaxAge))
+
+	for s.bucketCount > 0 && s.buckets[s.bucketHead].End.Before(cutoff) {
+		s.bucketHead = (s.bucketHead + 1) % len(s.buckets)
+		s.bucketCount--
+	}
+
+	for len(s.pending) > 0 && s.pending[0].Timestamp.Before(cutoff) {
+		s.pending = s.pending[1:]
+	}
+
+	for s.count > 0 && s.samples[s.head].Timestamp.Before(cutoff) {
+		s.head = (s.head + 1) % len(s.samples)
+		s.count--
+	}
+
+	// Rings that were emptied out start over so that they can keep growing contiguously
+	if s.bucketCount == 0 {
+		s.buckets = s.buckets[:0]
+		s.bucketHead = 0
+	}
+
+	if s.count == 0 {
+		s.samples = s.samples[:0]
+		s.head = 0
+	}
+}
+
+// Return the most recent sample (if any)
+func (s *series) last() (ProcInfoMetrics, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.count == 0 {
+		return ProcInfoMetrics{}, false
+	}
+
+	return s.samples[(s.head+s.count-1)%len(s.samples)], true
+}
+
+// Return the highest value of every metric; the timestamp is that of the most
+// recent sample
+func (s *series) peakMetrics() ProcInfoMetrics {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.peak
+}
+
+// Return (up to) the 'n' most recent samples, oldest first
+func (s *series) lastN(n int) []ProcInfoMetrics {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if n > s.count {
+		n = s.count
+	}
+
+	metrics := make([]ProcInfoMetrics, 0, n)
+
+	for i := s.count - n; i < s.count; i++ {
+		metrics = append(metrics, s.samples[(s.head+i)%len(s.samples)])
+	}
+
+	return metrics
+}
+
+// Return copies of raw samples starting at the absolute 'offset' (number of
+// samples appended since the watch started) and the offset to use for the
+// next incremental read. If 'offset' points at samples that have already been
+// evicted from the raw ring, all retained buckets are returned as well.
+func (s *series) read(offset int) ([]ProcInfoMetrics, []MetricsBucket, int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if offset < 0 || offset > s.total {
+		return nil, nil, 0, InvalidOffsetErr
+	}
+
+	oldest := s.total - s.count
+
+	var buckets []MetricsBucket
+
+	if offset < oldest {
+		buckets = make([]MetricsBucket, 0, s.bucketCount)
+
+		for i := 0; i < s.bucketCount; i++ {
+			buckets = append(buckets, s.buckets[(s.bucketHead+i)%len(s.buckets)])
+		}
+
+		offset = oldest
+	}
+
+	metrics := make([]ProcInfoMetrics, 0, s.total-offset)
+
+	for i := offset - oldest; i < s.count; i++ {
+		metrics = append(metrics, s.samples[(s.head+i)%len(s.samples)])
+	}
+
+	return metrics, buckets, s.total, nil
+}
+
+// Aggregate samples into a single min/max/avg bucket. Every numeric field in
+// ProcInfoMetrics is aggregated so new metrics do not need special handling.
+func newBucket(samples []ProcInfoMetrics) MetricsBucket {
+	bucket := MetricsBucket{
+		Start:   samples[0].Timestamp,
+		End:     samples[len(samples)-1].Timestamp,
+		Samples: len(samples),
+		Min:     samples[0],
+		Max:     samples[0],
+	}
+
+	sums := make(map[int]float64)
+
+	minValue := reflect.ValueOf(&bucket.Min).Elem()
+	maxValue := reflect.ValueOf(&bucket.Max).Elem()
+	avgValue := reflect.ValueOf(&bucket.Avg).Elem()
+
+	for _, sample := range samples {
+		sampleValue := reflect.ValueOf(sample)
+
+		for i := 0; i < sampleValue.NumField(); i++ {
+			v, ok := numericValue(sampleValue.Field(i))
+			if !ok {
+				continue
+			}
+
+			sums[i] += v
+
+			if lowest, _ := numericValue(minValue.Field(i)); v < lowest {
+				minValue.Field(i).Set(sampleValue.Field(i))
+			}
+
+			if highest, _ := numericValue(maxValue.Field(i)); v > highest {
+				maxValue.Field(i).Set(sampleValue.Field(i))
+			}
+		}
+	}
+
+	for i, sum := range sums {
+		setNumericValue(avgValue.Field(i), sum/float64(len(samples)))
+	}
+
+	// Every aggregate is reported at the midpoint of the bucket
+	midpoint := bucket.Start.Add(bucket.End.Sub(bucket.Start) / 2)
+
+	bucket.Min.Timestamp = midpoint
+	bucket.Max.Timestamp = midpoint
+	bucket.Avg.Timestamp = midpoint
+
+	return bucket
+}
+
+// Highest value of every numeric field of 'a' and 'b'; the timestamp of 'b' is kept
+func maxMetrics(a, b ProcInfoMetrics) ProcInfoMetrics {
+	peak := b
+
+	aValue := reflect.ValueOf(a)
+	peakValue := reflect.ValueOf(&peak).Elem()
+
+	for i := 0; i < aValue.NumField(); i++ {
+		v, ok := numericValue(aValue.Field(i))
+		if !ok {
+			continue
+		}
+
+		if highest, _ := numericValue(peakValue.Field(i)); v > highest {
+			peakValue.Field(i).Set(aValue.Field(i))
+		}
+	}
+
+	return peak
+}
+
+// Highest value of every metric across samples (oldest first)
+func peakOf(samples []ProcInfoMetrics) ProcInfoMetrics {
+	var peak ProcInfoMetrics
+
+	for i, m := range samples {
+		if i == 0 {
+			peak = m
+			continue
+		}
+
+		peak = maxMetrics(peak, m)
+	}
+
+	return peak
+}
+
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+func setNumericValue(v reflect.Value, f float64) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(f + 0.5))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(f + 0.5))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(f)
+	}
+}
@@ -0,0 +1,223 @@
+package stat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var seriesStart = time.Unix(1000, 0)
+
+// Sample 'i' has RSS 'i' and is taken 'i' seconds after seriesStart
+func sample(i int) ProcInfoMetrics {
+	return ProcInfoMetrics{
+		RSS:       uint64(i),
+		Timestamp: seriesStart.Add(time.Duration(i) * time.Second),
+	}
+}
+
+func newTestSeries(policy RetentionPolicy, n int) *series {
+	s := newSeries(policy)
+
+	for i := 0; i < n; i++ {
+		s.append(sample(i))
+	}
+
+	return s
+}
+
+func rssOf(metrics []ProcInfoMetrics) []uint64 {
+	values := make([]uint64, 0, len(metrics))
+
+	for _, m := range metrics {
+		values = append(values, m.RSS)
+	}
+
+	return values
+}
+
+func TestSeriesRingWraparound(t *testing.T) {
+	tests := []struct {
+		name    string
+		appends int
+		want    []uint64
+	}{
+		{"empty", 0, []uint64{}},
+		{"partially filled", 3, []uint64{0, 1, 2}},
+		{"exactly full", 4, []uint64{0, 1, 2, 3}},
+		{"wrapped once", 5, []uint64{1, 2, 3, 4}},
+		{"wrapped to the start", 8, []uint64{4, 5, 6, 7}},
+		{"wrapped several times", 11, []uint64{7, 8, 9, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSeries(RetentionPolicy{MaxSamples: 4}, tt.appends)
+
+			metrics, _, next, err := s.read(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rssOf(metrics); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read(0) = %v, want %v", got, tt.want)
+			}
+
+			if next != tt.appends {
+				t.Errorf("next offset = %v, want %v", next, tt.appends)
+			}
+
+			if got := rssOf(s.lastN(2)); len(tt.want) >= 2 && !reflect.DeepEqual(got, tt.want[len(tt.want)-2:]) {
+				t.Errorf("lastN(2) = %v, want %v", got, tt.want[len(tt.want)-2:])
+			}
+
+			last, ok := s.last()
+			if ok != (tt.appends > 0) || (ok && last.RSS != tt.want[len(tt.want)-1]) {
+				t.Errorf("last() = %v, %v", last.RSS, ok)
+			}
+
+			if len(s.samples) > 4 {
+				t.Errorf("ring grew to %v entries", len(s.samples))
+			}
+		})
+	}
+}
+
+func TestSeriesReadOffsets(t *testing.T) {
+	// 10 samples: 0-5 evicted into 3 buckets, 6-9 retained raw
+	policy := RetentionPolicy{MaxSamples: 4, BucketSize: 2}
+
+	tests := []struct {
+		name        string
+		offset      int
+		wantErr     error
+		wantRaw     []uint64
+		wantBuckets int
+	}{
+		{"from the start", 0, nil, []uint64{6, 7, 8, 9}, 3},
+		{"evicted offset returns buckets", 3, nil, []uint64{6, 7, 8, 9}, 3},
+		{"oldest retained sample", 6, nil, []uint64{6, 7, 8, 9}, 0},
+		{"incremental", 8, nil, []uint64{8, 9}, 0},
+		{"up to date", 10, nil, []uint64{}, 0},
+		{"beyond the newest sample", 11, InvalidOffsetErr, nil, 0},
+		{"negative", -1, InvalidOffsetErr, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSeries(policy, 10)
+
+			metrics, buckets, next, err := s.read(tt.offset)
+			if err != tt.wantErr {
+				t.Fatalf("read(%v) error = %v, want %v", tt.offset, err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := rssOf(metrics); !reflect.DeepEqual(got, tt.wantRaw) {
+				t.Errorf("read(%v) = %v, want %v", tt.offset, got, tt.wantRaw)
+			}
+
+			if len(buckets) != tt.wantBuckets {
+				t.Errorf("read(%v) returned %v buckets, want %v", tt.offset, len(buckets), tt.wantBuckets)
+			}
+
+			if next != 10 {
+				t.Errorf("next offset = %v, want 10", next)
+			}
+		})
+	}
+}
+
+func TestSeriesInvalidOffsetAfterExpiry(t *testing.T) {
+	s := newSeries(RetentionPolicy{MaxSamples: 4, BucketSize: 2, MaxAge: Duration(3 * time.Second)})
+
+	for i := 0; i < 10; i++ {
+		s.append(sample(i))
+	}
+
+	// Everything but the last 3s expired; offsets keep counting regardless
+	metrics, buckets, next, err := s.read(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rssOf(metrics); !reflect.DeepEqual(got, []uint64{6, 7, 8, 9}) {
+		t.Errorf("read(0) = %v", got)
+	}
+
+	if len(buckets) != 0 {
+		t.Errorf("expected expired buckets to be dropped, got %v", len(buckets))
+	}
+
+	if next != 10 {
+		t.Errorf("next offset = %v, want 10", next)
+	}
+
+	if _, _, _, err := s.read(11); err != InvalidOffsetErr {
+		t.Errorf("read(11) error = %v, want InvalidOffsetErr", err)
+	}
+}
+
+func TestSeriesBucketRollup(t *testing.T) {
+	type bucket struct {
+		samples       int
+		min, max, avg uint64
+		start, end    int
+	}
+
+	// Only the 2 newest samples are kept raw; every 3 evicted samples make a bucket
+	policy := RetentionPolicy{MaxSamples: 2, BucketSize: 3, MaxBuckets: 2}
+
+	tests := []struct {
+		name    string
+		appends int
+		want    []bucket
+	}{
+		{"nothing evicted", 2, []bucket{}},
+		{"pending samples are not a bucket yet", 4, []bucket{}},
+		{"first bucket", 5, []bucket{{3, 0, 2, 1, 0, 2}}},
+		{"second bucket", 8, []bucket{{3, 0, 2, 1, 0, 2}, {3, 3, 5, 4, 3, 5}}},
+		{"oldest bucket dropped", 11, []bucket{{3, 3, 5, 4, 3, 5}, {3, 6, 8, 7, 6, 8}}},
+		{"bucket ring wrapped", 14, []bucket{{3, 6, 8, 7, 6, 8}, {3, 9, 11, 10, 9, 11}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSeries(policy, tt.appends)
+
+			_, buckets, _, err := s.read(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]bucket, 0, len(buckets))
+
+			for _, b := range buckets {
+				got = append(got, bucket{
+					samples: b.Samples,
+					min:     b.Min.RSS,
+					max:     b.Max.RSS,
+					avg:     b.Avg.RSS,
+					start:   int(b.Start.Sub(seriesStart) / time.Second),
+					end:     int(b.End.Sub(seriesStart) / time.Second),
+				})
+
+				if mid := b.Start.Add(b.End.Sub(b.Start) / 2); !b.Avg.Timestamp.Equal(mid) {
+					t.Errorf("bucket aggregates at %v, want midpoint %v", b.Avg.Timestamp, mid)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buckets = %+v, want %+v", got, tt.want)
+			}
+
+			// Peak covers evicted samples too
+			if peak := s.peakMetrics().RSS; peak != uint64(tt.appends-1) {
+				t.Errorf("peak = %v, want %v", peak, tt.appends-1)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package stat
 import (
 	"errors"
 	"fmt"
+	"os/user"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,8 +17,12 @@ import (
 )
 
 const (
+	// Defaults (see Settings)
 	CacheProcessListInterval = 5 * time.Second
 	StatInterval             = 5 * time.Second
+
+	// Lowest per-watch sampling interval that is accepted
+	MinStatInterval = 100 * time.Millisecond
 )
 
 var (
@@ -23,33 +30,140 @@ var (
 	AlreadyWatchedErr = errors.New("pid is already being watched")
 	InvalidOffsetErr  = errors.New("invalid offset")
 
+	processGoneErr = errors.New("process is no longer running (or its pid has been re-used)")
+
 	sugar *zap.SugaredLogger
 )
 
 type Statter interface {
 	GetProcesses() ([]ProcInfo, error)
+	FindProcesses(filter ProcessFilter) (ProcessPage, error)
+	GetProcessTree(filter ProcessFilter) ([]ProcessNode, error)
 	GetStatsForPID(pid int32, offset int) (ProcInfo, error)
-	StartWatchProcess(pid int32) error
+	QueryStatsForPID(pid int32, query Query) (ProcInfo, error)
+	GetWatchedProcesses() ([]ProcInfo, error)
+	GetSummaryForPID(pid int32, from, to time.Time) (Summary, error)
+	GetFDsForPID(pid int32) ([]FileDescriptor, error)
+	GetThreadsForPID(pid int32) ([]ThreadInfo, error)
+	GetHistoryForPID(pid int32, offset int) (WatchHistory, error)
+	GetFinishedWatches() ([]WatchHistory, error)
+	DeleteHistoryForPID(pid int32) error
+	Subscribe(pid int32) (*Subscription, error)
+	Unsubscribe(sub *Subscription)
+	GetRules() ([]WatchRule, error)
+	AddRule(rule WatchRule) (WatchRule, error)
+	DeleteRule(id string) error
+	GetAlerts() ([]Alert, error)
+	GetAlertRules() ([]AlertRule, error)
+	AddAlertRule(rule AlertRule) (AlertRule, error)
+	DeleteAlertRule(id string) error
+	StartWatchProcess(pid int32, config WatchConfig) error
+	SetWatchInterval(pid int32, interval time.Duration) error
 	StopWatchProcess(pid int32) error
+	Reconfigure(settings Settings) error
+}
+
+// Settings are the tunables of a Stat; zero values mean "use the default"
+type Settings struct {
+	// How often the process list is refreshed (and rules are applied)
+	CacheProcessListInterval time.Duration
+
+	// Sampling interval for watches that do not set their own
+	StatInterval time.Duration
+
+	// Where alerts get delivered to (in addition to the log)
+	Notifiers []Notifier
+}
+
+// Validate the settings
+func (s Settings) Validate() error {
+	if s.CacheProcessListInterval != 0 && s.CacheProcessListInterval < MinStatInterval {
+		return fmt.Errorf("process list interval must be at least %v", MinStatInterval)
+	}
+
+	if s.StatInterval != 0 && s.StatInterval < MinStatInterval {
+		return fmt.Errorf("stat interval must be at least %v", MinStatInterval)
+	}
+
+	return nil
+}
+
+func (s Settings) withDefaults() Settings {
+	if s.CacheProcessListInterval == 0 {
+		s.CacheProcessListInterval = CacheProcessListInterval
+	}
+
+	if s.StatInterval == 0 {
+		s.StatInterval = StatInterval
+	}
+
+	return s
 }
 
 type Stat struct {
 	// ProcInfo list
 	processList []ProcInfo
 
-	// Looper used for fetching + caching process list
-	processListLooper *director.TimedLooper
+	// Looper used for fetching + caching process list (and its interval)
+	processListLooper   *director.TimedLooper
+	processListInterval time.Duration
 
-	// Lock used for accessing process list
+	// Lock used for accessing process list + its looper
 	processListLock *sync.Mutex
 
+	// CPU times per pid as of the previous fetch + resolved user names by uid
+	cpuTimes  map[int32]cpuTimes
+	userNames map[uint32]string
+
+	// Serializes process list fetches (a replaced looper may still be in
+	// flight) + guards cpuTimes and userNames
+	fetchLock *sync.Mutex
+
 	// Map containing all actively watched processes (and their info)
 	watched map[int32]*Proc
 
-	// TODO: Should we keep history?
+	// Sampling interval for watches that do not set their own
+	statInterval time.Duration
+
+	// Watches whose process has exited; kept (with their metrics) until they
+	// are deleted or the pid is watched again
+	finished map[int32]*Proc
 
-	// Lock used for accessing watched map
+	// Lock used for accessing watched + finished maps and statInterval
 	watchedLock *sync.Mutex
+
+	// Persists watches + metrics (and keeps history for watches that are gone)
+	storage Storage
+
+	// Live metric subscribers per pid
+	subscribers map[int32]map[*Subscription]struct{}
+
+	// Lock used for accessing subscribers map
+	subscribersLock *sync.Mutex
+
+	// Watch rules by ID
+	rules map[string]*WatchRule
+
+	// Pids whose rule-started watch was stopped by hand (or failed to collect
+	// metrics); rules will not re-attach to them
+	ruleIgnored map[int32]struct{}
+
+	// Lock used for accessing rules + ruleIgnored maps
+	rulesLock *sync.Mutex
+
+	// Alert rules by ID + per rule/pid evaluation state
+	alertRules     map[string]*AlertRule
+	alertStates    map[alertKey]*alertState
+	resolvedAlerts []Alert
+
+	// Lock used for accessing alertRules, alertStates + resolvedAlerts
+	alertsLock *sync.Mutex
+
+	// Where alerts get delivered to (one worker per notifier)
+	notifiers []*notifyWorker
+
+	// Shared parent -> children index for tree watches
+	childIndex *childIndex
 }
 
 type Proc struct {
@@ -57,6 +171,62 @@ type Proc struct {
 	Looper   *director.TimedLooper
 	Process  *process.Process
 	Err      error // If set, we know we do not need to .Quit on the looper
+
+	// Collected metrics (bounded by the watch's retention policy)
+	series *series
+
+	// Children of the process; only set for tree watches
+	tree *processTree
+
+	// Threads of the process; only set for threads watches
+	threads *threadTracker
+
+	// Current config of the watch (interval can change while running)
+	config WatchConfig
+
+	// Serializes sample collection (while the looper is swapped out)
+	sampleLock *sync.Mutex
+
+	startedAt time.Time
+
+	// Set once the process has exited (finished watches only)
+	exit *ExitInfo
+}
+
+// WatchConfig contains per-watch settings; zero values mean "use the default"
+type WatchConfig struct {
+	Retention RetentionPolicy `json:"retention"`
+
+	// Also watch all descendants of the process
+	Tree bool `json:"tree,omitempty"`
+
+	// How often a sample is collected (default: Settings.StatInterval)
+	Interval Duration `json:"interval,omitempty"`
+
+	// Also collect PSS, USS, shared + anonymous memory from smaps; noticeably
+	// more expensive for processes with many mappings
+	Smaps bool `json:"smaps,omitempty"`
+
+	// Also sample every thread of the process (name, state + CPU time)
+	Threads bool `json:"threads,omitempty"`
+}
+
+// Validate the watch config
+func (c WatchConfig) Validate() error {
+	if c.Interval != 0 && time.Duration(c.Interval) < MinStatInterval {
+		return fmt.Errorf("interval must be at least %v", MinStatInterval)
+	}
+
+	return nil
+}
+
+// Effective sampling interval
+func (c WatchConfig) interval(fallback time.Duration) time.Duration {
+	if c.Interval == 0 {
+		return fallback
+	}
+
+	return time.Duration(c.Interval)
 }
 
 type ProcInfo struct {
@@ -66,22 +236,106 @@ type ProcInfo struct {
 	CmdLine string `json:"cmd_line"`
 	Watched bool   `json:"watched"`
 
+	// Parent pid + when the process was started
+	PPID      int32      `json:"ppid,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+
+	// Process list only: owner, state (R, S, D, Z, T, ...) + resource usage
+	// as of the last refresh (CPU is averaged over the process list interval)
+	User  string  `json:"user,omitempty"`
+	State string  `json:"state,omitempty"`
+	CPU   float64 `json:"cpu,omitempty"`
+	RSS   uint64  `json:"rss,omitempty"`
+
+	// ID of the rule that started the watch (if any)
+	Rule string `json:"rule,omitempty"`
+
+	// Effective sampling interval of the watch
+	Interval Duration `json:"interval,omitempty"`
+
+	// When the watch was started; tells a watch that was restarted on the same
+	// pid apart from the previous one
+	WatchedSince *time.Time `json:"watched_since,omitempty"`
+
+	// Tree watches only: summed metrics of the process + all of its children
+	// (lined up with Metrics and Buckets) and every current child with its own metrics for the same ticks
+	TreeMetrics []ProcInfoMetrics `json:"tree_metrics,omitempty"`
+	TreeBuckets []MetricsBucket   `json:"tree_buckets,omitempty"`
+	Children    []ProcInfo        `json:"children,omitempty"`
+
 	// Available only in Proc.Metrics
-	Metrics     []ProcInfoMetrics `json:"metrics"`
-	MetricsLock *sync.Mutex       `json:"-"`
+	Metrics []ProcInfoMetrics `json:"metrics"`
+
+	// Downsampled history; only included when the requested offset reaches
+	// past the raw samples that are still retained
+	Buckets []MetricsBucket `json:"buckets,omitempty"`
+
+	// Offset to use for fetching only new metrics on the next request
+	NextOffset int `json:"next_offset,omitempty"`
+
+	// Effective retention policy for the watch
+	Retention *RetentionPolicy `json:"retention,omitempty"`
 }
 
 type ProcInfoMetrics struct {
-	VMS       uint64    `json:"vms"`
-	RSS       uint64    `json:"rss"`
-	Swap      uint64    `json:"swap"`
-	CPU       float64   `json:"cpu"`
-	Threads   int32     `json:"threads"`
+	VMS     uint64  `json:"vms"`
+	RSS     uint64  `json:"rss"`
+	Swap    uint64  `json:"swap"`
+	CPU     float64 `json:"cpu"`
+	Threads int32   `json:"threads"`
+
+	// Disk I/O counters (cumulative, from /proc/<pid>/io)
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	ReadCount  uint64 `json:"read_count"`
+	WriteCount uint64 `json:"write_count"`
+
+	// Disk I/O throughput since the previous sample (bytes/sec)
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+
+	// Context switches + page faults (cumulative, from /proc/<pid>/status and
+	// /proc/<pid>/stat)
+	VoluntaryCtxSwitches   uint64 `json:"voluntary_ctx_switches"`
+	InvoluntaryCtxSwitches uint64 `json:"involuntary_ctx_switches"`
+	MinorFaults            uint64 `json:"minor_faults"`
+	MajorFaults            uint64 `json:"major_faults"`
+
+	// Time spent runnable but waiting for a CPU (cumulative seconds, from
+	// /proc/<pid>/schedstat; 0 if the kernel has no schedstats)
+	RunQueueWait float64 `json:"run_queue_wait"`
+
+	// Increase of the above counters since the previous sample
+	VoluntaryCtxSwitchesDelta   uint64  `json:"voluntary_ctx_switches_delta"`
+	InvoluntaryCtxSwitchesDelta uint64  `json:"involuntary_ctx_switches_delta"`
+	MinorFaultsDelta            uint64  `json:"minor_faults_delta"`
+	MajorFaultsDelta            uint64  `json:"major_faults_delta"`
+	RunQueueWaitDelta           float64 `json:"run_queue_wait_delta"`
+
+	// Memory accounting from /proc/<pid>/smaps_rollup (bytes); only collected
+	// for watches with WatchConfig.Smaps. PSS splits shared pages evenly
+	// between the processes sharing them, USS only counts private pages.
+	PSS         uint64 `json:"pss,omitempty"`
+	USS         uint64 `json:"uss,omitempty"`
+	SharedClean uint64 `json:"shared_clean,omitempty"`
+	SharedDirty uint64 `json:"shared_dirty,omitempty"`
+	Anonymous   uint64 `json:"anonymous,omitempty"`
+
+	// Open fds (by type) + soft/hard RLIMIT_NOFILE (0 means unlimited); fds
+	// are only readable by the process owner (or root)
+	FDs          uint64 `json:"fds"`
+	FileFDs      uint64 `json:"file_fds"`
+	SocketFDs    uint64 `json:"socket_fds"`
+	PipeFDs      uint64 `json:"pipe_fds"`
+	AnonInodeFDs uint64 `json:"anon_inode_fds"`
+	FDSoftLimit  uint64 `json:"fd_soft_limit"`
+	FDHardLimit  uint64 `json:"fd_hard_limit"`
+
 	Timestamp time.Time `json:"timestamp"`
 }
 
 func init() {
-	logger, err := util.CreateLogger(false, map[string]interface{}{"pkg": "stat"})
+	logger, err := util.CreateLogger(map[string]interface{}{"pkg": "stat"})
 	if err != nil {
 		panic(fmt.Sprintf("unable to setup logger: %v", err))
 	}
@@ -89,23 +343,121 @@ func init() {
 	sugar = logger.Sugar()
 }
 
-func New() (*Stat, error) {
+// New creates a Stat; 'storage' may be nil in which case nothing is persisted.
+// Alerts are always logged and additionally delivered to every notifier in
+// 'settings'.
+func New(storage Storage, settings Settings) (*Stat, error) {
+	if storage == nil {
+		storage = &nopStorage{}
+	}
+
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	settings = settings.withDefaults()
+
 	s := &Stat{
-		processListLooper: director.NewImmediateTimedLooper(director.FOREVER, CacheProcessListInterval, nil),
-		processListLock:   &sync.Mutex{},
-		processList:       make([]ProcInfo, 0),
-		watchedLock:       &sync.Mutex{},
-		watched:           make(map[int32]*Proc, 0),
+		processListLooper:   director.NewImmediateTimedLooper(director.FOREVER, settings.CacheProcessListInterval, nil),
+		processListInterval: settings.CacheProcessListInterval,
+		processListLock:     &sync.Mutex{},
+		processList:         make([]ProcInfo, 0),
+		cpuTimes:            make(map[int32]cpuTimes, 0),
+		userNames:           make(map[uint32]string, 0),
+		fetchLock:           &sync.Mutex{},
+		statInterval:        settings.StatInterval,
+		watchedLock:         &sync.Mutex{},
+		watched:             make(map[int32]*Proc, 0),
+		finished:            make(map[int32]*Proc, 0),
+		storage:             storage,
+		subscribers:         make(map[int32]map[*Subscription]struct{}, 0),
+		subscribersLock:     &sync.Mutex{},
+		rules:               make(map[string]*WatchRule, 0),
+		ruleIgnored:         make(map[int32]struct{}, 0),
+		rulesLock:           &sync.Mutex{},
+		alertRules:          make(map[string]*AlertRule, 0),
+		alertStates:         make(map[alertKey]*alertState, 0),
+		resolvedAlerts:      make([]Alert, 0),
+		alertsLock:          &sync.Mutex{},
+		notifiers:           newNotifyWorkers(append([]Notifier{&LogNotifier{}}, settings.Notifiers...)),
+		childIndex:          newChildIndex(),
+	}
+
+	// Restored watches are evaluated right away
+	if err := s.loadAlertRules(); err != nil {
+		return nil, fmt.Errorf("unable to load alert rules: %v", err)
+	}
+
+	// Resume watches that were active before we were restarted
+	if err := s.restoreWatches(); err != nil {
+		return nil, fmt.Errorf("unable to restore watches: %v", err)
+	}
+
+	if err := s.loadRules(); err != nil {
+		return nil, fmt.Errorf("unable to load rules: %v", err)
 	}
 
 	// run processlist fetcher on an interval
-	go s.cacheProcessList()
+	go s.cacheProcessList(s.processListLooper)
 
 	return s, nil
 }
 
-func (s *Stat) cacheProcessList() error {
-	s.processListLooper.Loop(func() error {
+// Reconfigure applies new settings at runtime; running watches that do not set
+// their own interval switch to the new default sampling interval
+func (s *Stat) Reconfigure(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	settings = settings.withDefaults()
+
+	s.alertsLock.Lock()
+
+	for _, worker := range s.notifiers {
+		worker.stop()
+	}
+
+	s.notifiers = newNotifyWorkers(append([]Notifier{&LogNotifier{}}, settings.Notifiers...))
+
+	s.alertsLock.Unlock()
+
+	s.processListLock.Lock()
+
+	if settings.CacheProcessListInterval != s.processListInterval {
+		looper := director.NewTimedLooper(director.FOREVER, settings.CacheProcessListInterval, nil)
+
+		s.processListLooper.Quit()
+		s.processListLooper = looper
+		s.processListInterval = settings.CacheProcessListInterval
+
+		go s.cacheProcessList(looper)
+	}
+
+	s.processListLock.Unlock()
+
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	if settings.StatInterval == s.statInterval {
+		return nil
+	}
+
+	s.statInterval = settings.StatInterval
+
+	for _, watchedProc := range s.watched {
+		if watchedProc.Err != nil || watchedProc.config.Interval != 0 {
+			continue
+		}
+
+		s.restartCollect(watchedProc)
+	}
+
+	return nil
+}
+
+func (s *Stat) cacheProcessList(looper *director.TimedLooper) error {
+	looper.Loop(func() error {
 		processList, err := s.fetchProcessList()
 		if err != nil {
 			sugar.Errorf("unable to fetch processlist: %v", err)
@@ -116,6 +468,9 @@ func (s *Stat) cacheProcessList() error {
 		s.processList = processList
 		s.processListLock.Unlock()
 
+		// Attach watches to any new processes that match a rule
+		s.applyRules(processList)
+
 		return nil
 	})
 
@@ -123,40 +478,126 @@ func (s *Stat) cacheProcessList() error {
 	return nil
 }
 
+// Refresh the process list; every process costs one read of its stat +
+// cmdline file (and a stat of its /proc dir)
 func (s *Stat) fetchProcessList() ([]ProcInfo, error) {
-	processes, err := process.Processes()
+	s.fetchLock.Lock()
+	defer s.fetchLock.Unlock()
+
+	pids, err := process.Pids()
 	if err != nil {
 		return nil, err
 	}
 
-	entries := make([]ProcInfo, 0)
+	entries := make([]ProcInfo, 0, len(pids))
+	seen := make(map[int32]cpuTimes, len(pids))
 
-	for _, p := range processes {
-		entry := ProcInfo{
-			PID: p.Pid,
-		}
-
-		cmdLine, err := p.Cmdline()
+	for _, pid := range pids {
+		st, err := readProcStat(pid)
 		if err != nil {
-			//sugar.Debugf("unable to determine cmdline for stat '%v': %v", p.Pid, err)
+			// Exited in the meantime
 			continue
 		}
 
-		name, err := p.Name()
+		cmdLine, err := readCmdLine(pid)
 		if err != nil {
-			//sugar.Debugf("unable to determine name for stat '%v': %v", p.Pid, err)
 			continue
 		}
 
-		entry.Name = name
-		entry.CmdLine = cmdLine
+		entry := procInfoFromStat(pid, st, cmdLine)
+		entry.User = s.userName(pid)
+
+		cur := cpuTimes{total: st.UTime + st.STime, startTime: st.StartTime, at: time.Now()}
+
+		if prev, ok := s.cpuTimes[pid]; ok {
+			entry.CPU = cur.percent(prev)
+		}
+
+		seen[pid] = cur
 
 		entries = append(entries, entry)
 	}
 
+	// Forget about processes that are gone
+	s.cpuTimes = seen
+
 	return entries, nil
 }
 
+// Cumulative CPU time (seconds) of a process at a point in time
+type cpuTimes struct {
+	total     float64
+	startTime time.Time
+	at        time.Time
+}
+
+// CPU usage (%) between two points in time
+func (c cpuTimes) percent(prev cpuTimes) float64 {
+	elapsed := c.at.Sub(prev.at).Seconds()
+
+	// Different process with a re-used pid
+	if !c.startTime.Equal(prev.startTime) {
+		return 0
+	}
+
+	if elapsed <= 0 || c.total < prev.total {
+		return 0
+	}
+
+	return (c.total - prev.total) / elapsed * 100
+}
+
+// Name of the user owning a process; falls back to the uid if it cannot be
+// resolved. Caller must hold fetchLock.
+func (s *Stat) userName(pid int32) string {
+	uid, err := procOwner(pid)
+	if err != nil {
+		return ""
+	}
+
+	if name, ok := s.userNames[uid]; ok {
+		return name
+	}
+
+	name := strconv.Itoa(int(uid))
+
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+
+	s.userNames[uid] = name
+
+	return name
+}
+
+func newProcInfo(p *process.Process) (ProcInfo, error) {
+	st, err := readProcStat(p.Pid)
+	if err != nil {
+		return ProcInfo{}, err
+	}
+
+	cmdLine, err := readCmdLine(p.Pid)
+	if err != nil {
+		return ProcInfo{}, err
+	}
+
+	return procInfoFromStat(p.Pid, st, cmdLine), nil
+}
+
+func procInfoFromStat(pid int32, st procStat, cmdLine string) ProcInfo {
+	startTime := st.StartTime
+
+	return ProcInfo{
+		PID:       pid,
+		PPID:      st.PPID,
+		Name:      st.Comm,
+		CmdLine:   cmdLine,
+		State:     st.State,
+		StartTime: &startTime,
+		RSS:       st.RSS,
+	}
+}
+
 // Get a list of all running processes (from cache)
 func (s *Stat) GetProcesses() ([]ProcInfo, error) {
 	s.processListLock.Lock()
@@ -204,11 +645,32 @@ func (s *Stat) isWatched(pid int32) bool {
 }
 
 // Start gathering watched for a specific process
-func (s *Stat) StartWatchProcess(pid int32) error {
+func (s *Stat) StartWatchProcess(pid int32, config WatchConfig) error {
 	// Is this is known pid?
 	procInfo, err := s.getProcInfoProcessList(pid)
 	if err != nil {
-		return fmt.Errorf("pid '%v' is not in process list", pid)
+		// Process list is only refreshed every CacheProcessListInterval; look
+		// up brand new processes directly
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			return fmt.Errorf("pid '%v' is not in process list", pid)
+		}
+
+		procInfo, err = newProcInfo(p)
+		if err != nil {
+			return fmt.Errorf("pid '%v' is not in process list", pid)
+		}
+	}
+
+	return s.newWatch(procInfo, config)
+}
+
+// Start a brand new watch for a process from the process list
+func (s *Stat) newWatch(procInfo ProcInfo, config WatchConfig) error {
+	pid := procInfo.PID
+
+	if err := config.Validate(); err != nil {
+		return err
 	}
 
 	// Is the process already being watched?
@@ -216,10 +678,6 @@ func (s *Stat) StartWatchProcess(pid int32) error {
 		return AlreadyWatchedErr
 	}
 
-	// Get internals ready
-	procInfo.Metrics = make([]ProcInfoMetrics, 0)
-	procInfo.MetricsLock = &sync.Mutex{}
-
 	// Instantiate process
 	proc, err := process.NewProcess(pid)
 	if err != nil {
@@ -231,18 +689,156 @@ func (s *Stat) StartWatchProcess(pid int32) error {
 		return fmt.Errorf("unable to fetch initial status for pid '%v': %v", pid, err)
 	}
 
+	createTime, err := proc.CreateTime()
+	if err != nil {
+		return fmt.Errorf("unable to fetch create time for pid '%v': %v", pid, err)
+	}
+
+	// This is a brand new watch - anything stored for this pid belongs to an older one
+	if err := s.storage.ResetMetrics(pid); err != nil {
+		return fmt.Errorf("unable to reset stored metrics for pid '%v': %v", pid, err)
+	}
+
+	startedAt := time.Now()
+
+	if err := s.storage.SaveWatch(WatchRecord{
+		PID:        pid,
+		Name:       procInfo.Name,
+		CmdLine:    procInfo.CmdLine,
+		Config:     config,
+		Rule:       procInfo.Rule,
+		CreateTime: createTime,
+		StartedAt:  startedAt,
+		Active:     true,
+	}); err != nil {
+		return fmt.Errorf("unable to persist watch for pid '%v': %v", pid, err)
+	}
+
+	return s.startWatch(procInfo, proc, config, startedAt, nil)
+}
+
+func (s *Stat) restoreWatches() error {
+	records, err := s.storage.GetWatches()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if !record.Active {
+			continue
+		}
+
+		if err := s.restoreWatch(record); err != nil {
+			sugar.Warnf("unable to resume watch for pid '%v' (keeping history only): %v", record.PID, err)
+
+			record.Active = false
+
+			// Exited while we were not running; last sample is as close as it gets
+			if err == processGoneErr {
+				record.Exit = &ExitInfo{ExitedAt: record.StartedAt, Reason: ExitReasonGone}
+
+				if history, err := s.storage.GetMetrics(record.PID); err == nil && len(history) > 0 {
+					record.Exit.ExitedAt = history[len(history)-1].Timestamp
+				}
+			}
+
+			if err := s.storage.SaveWatch(record); err != nil {
+				sugar.Errorf("unable to deactivate watch for pid '%v': %v", record.PID, err)
+			}
+
+			continue
+		}
+
+		sugar.Infof("resumed watch for pid '%v'", record.PID)
+	}
+
+	return nil
+}
+
+func (s *Stat) restoreWatch(record WatchRecord) error {
+	proc, err := process.NewProcess(record.PID)
+	if err != nil {
+		return processGoneErr
+	}
+
+	// Make sure it's still the same process and not a re-used PID
+	createTime, err := proc.CreateTime()
+	if err != nil || createTime != record.CreateTime {
+		return processGoneErr
+	}
+
+	history, err := s.storage.GetMetrics(record.PID)
+	if err != nil {
+		return fmt.Errorf("unable to load stored metrics: %v", err)
+	}
+
+	procInfo := ProcInfo{
+		PID:     record.PID,
+		Name:    record.Name,
+		CmdLine: record.CmdLine,
+		Rule:    record.Rule,
+	}
+
+	return s.startWatch(procInfo, proc, record.Config, record.StartedAt, history)
+}
+
+// Add process to the watched map and start collecting metrics for it;
+// 'history' contains previously collected samples (if any)
+func (s *Stat) startWatch(procInfo ProcInfo, proc *process.Process, config WatchConfig, startedAt time.Time,
+	history []ProcInfoMetrics) error {
+	pid := procInfo.PID
+
 	// Set watched state (non-critical, display purposes)
 	procInfo.Watched = true
 
-	looper := director.NewImmediateTimedLooper(director.FOREVER, StatInterval, nil)
+	// State + usage are a process list snapshot; samples have the real numbers
+	procInfo.State = ""
+	procInfo.CPU = 0
+	procInfo.RSS = 0
+
+	procInfo.WatchedSince = &startedAt
+
+	series := newSeries(config.Retention)
+
+	for _, m := range history {
+		series.append(m)
+	}
+
+	var tree *processTree
+
+	if config.Tree {
+		tree = newProcessTree(series.policy, len(history))
+	}
+
+	var threads *threadTracker
+
+	if config.Threads {
+		threads = newThreadTracker(series.policy)
+	}
 
 	// Update watched map
 	s.watchedLock.Lock()
 
+	if _, ok := s.watched[pid]; ok {
+		s.watchedLock.Unlock()
+		return AlreadyWatchedErr
+	}
+
+	looper := director.NewImmediateTimedLooper(director.FOREVER, config.interval(s.statInterval), nil)
+
+	// History of an earlier process with the same pid is gone from storage now
+	delete(s.finished, pid)
+
 	s.watched[pid] = &Proc{
-		ProcInfo: procInfo,
-		Process:  proc,
-		Looper:   looper,
+		ProcInfo:   procInfo,
+		Process:    proc,
+		Looper:     looper,
+		series:     series,
+		tree:       tree,
+		threads:    threads,
+		config:     config,
+		sampleLock: &sync.Mutex{},
+		startedAt:  startedAt,
 	}
 
 	watchedProc := s.watched[pid]
@@ -250,61 +846,178 @@ func (s *Stat) StartWatchProcess(pid int32) error {
 	s.watchedLock.Unlock()
 
 	// Gather watched in a goroutine
-	go func(watchedProc *Proc) {
-		// Stop watching process if loop ever exits
-		defer func(pid int32) {
-			// Should only get ran if loop exited on err
-			if watchedProc.Err == nil {
-				return
-			}
+	go s.collect(watchedProc, looper)
 
-			if err := s.StopWatchProcess(pid); err != nil {
-				sugar.Errorf("unable to stop watching pid '%v': %v", pid, err)
-			}
-		}(watchedProc.Process.Pid)
+	return nil
+}
 
-		watchedProc.Looper.Loop(func() error {
-			sugar.Debugf("Fetching metrics for pid '%v'", watchedProc.Process.Pid)
+// Change the sampling interval of a running watch; collected history is kept
+func (s *Stat) SetWatchInterval(pid int32, interval time.Duration) error {
+	config := WatchConfig{Interval: Duration(interval)}
 
-			// Is the process still around?
-			if _, err := watchedProc.Process.Status(); err != nil {
-				fullErr := fmt.Errorf("cannot fetch watched pid '%v' status (no longer running?): %v", pid, err)
-				sugar.Error(fullErr)
+	if err := config.Validate(); err != nil {
+		return err
+	}
 
-				// Prevent StopWatchProcess() from attempting to .Quit the looper (and block forever)
-				watchedProc.Err = fullErr
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
 
-				return fullErr
-			}
+	watchedProc, ok := s.watched[pid]
+	if !ok {
+		return NotWatchedErr
+	}
 
-			// Generate watched for the process
-			metrics, err := s.getMetrics(watchedProc.Process)
-			if err != nil {
-				fullErr := fmt.Errorf("unable to fetch metrics for pid '%v': %v", pid, err)
-				sugar.Error(fullErr)
+	// Loop has already exited on its own; watch is about to be removed
+	if watchedProc.Err != nil {
+		return NotWatchedErr
+	}
 
-				// Prevent StopWatchProcess() from attempting to .Quit the looper (and block forever)
-				watchedProc.Err = fullErr
+	watchedProc.config.Interval = config.Interval
 
-				return fullErr
-			}
+	s.restartCollect(watchedProc)
 
-			// Save metrics
-			watchedProc.ProcInfo.MetricsLock.Lock()
-			watchedProc.ProcInfo.Metrics = append(watchedProc.ProcInfo.Metrics, *metrics)
-			watchedProc.ProcInfo.MetricsLock.Unlock()
+	// Restore with the new interval
+	record, err := s.storage.GetWatch(pid)
+	if err != nil {
+		return nil
+	}
+
+	record.Config.Interval = config.Interval
+
+	if err := s.storage.SaveWatch(record); err != nil {
+		return fmt.Errorf("unable to persist interval for pid '%v': %v", pid, err)
+	}
+
+	return nil
+}
 
+// Swap in a new looper using the current interval of the watch; the first
+// sample is taken one interval from now so there is no extra sample in
+// between. Caller must hold watchedLock.
+func (s *Stat) restartCollect(watchedProc *Proc) {
+	looper := director.NewTimedLooper(director.FOREVER, watchedProc.config.interval(s.statInterval), nil)
+
+	watchedProc.Looper.Quit()
+	watchedProc.Looper = looper
+
+	go s.collect(watchedProc, looper)
+}
+
+// Collect samples on every tick of 'looper' until it is quit (or the process
+// goes away)
+func (s *Stat) collect(watchedProc *Proc, looper *director.TimedLooper) {
+	pid := watchedProc.ProcInfo.PID
+
+	// Set once the loop exits on its own
+	var loopErr error
+
+	// Move to the finished watches if loop ever exits
+	defer func() {
+		// Should only get ran if loop exited on err
+		if loopErr == nil {
+			return
+		}
+
+		s.finishWatch(watchedProc)
+	}()
+
+	looper.Loop(func() error {
+		// Iterations of an old (replaced) looper may still be in flight
+		watchedProc.sampleLock.Lock()
+		defer watchedProc.sampleLock.Unlock()
+
+		// Quit() does not stop the looper right away; it can fire once more
+		// after the watch was stopped or its looper was swapped out
+		if !s.isCurrentLooper(watchedProc, looper) {
 			return nil
-		})
+		}
 
-		sugar.Debugf("process watch for '%v' exiting...", watchedProc.ProcInfo.PID)
+		sugar.Debugf("Fetching metrics for pid '%v'", watchedProc.Process.Pid)
 
-	}(watchedProc)
+		// Is the process still around?
+		if exit := processExit(pid); exit != nil {
+			watchedProc.exit = exit
 
-	return nil
+			loopErr = fmt.Errorf("pid '%v' is no longer running (%v)", pid, exit.Reason)
+
+			s.setWatchErr(watchedProc, loopErr)
+
+			return loopErr
+		}
+
+		// Previous sample is needed for calculating per-interval rates
+		var prev *ProcInfoMetrics
+
+		if last, ok := watchedProc.series.last(); ok {
+			prev = &last
+		}
+
+		// Generate watched for the process
+		metrics, err := s.getMetrics(watchedProc.Process, prev, watchedProc.config.Smaps)
+		if err != nil {
+			fullErr := fmt.Errorf("unable to fetch metrics for pid '%v': %v", pid, err)
+
+			// Exited in the meantime?
+			watchedProc.exit = processExit(pid)
+
+			if watchedProc.exit == nil {
+				sugar.Error(fullErr)
+
+				watchedProc.exit = &ExitInfo{ExitedAt: time.Now(), Reason: ExitReasonError, Error: err.Error()}
+			}
+
+			loopErr = fullErr
+
+			s.setWatchErr(watchedProc, loopErr)
+
+			return loopErr
+		}
+
+		// Save metrics
+		watchedProc.series.append(*metrics)
+
+		if err := s.storage.AppendMetrics(pid, *metrics); err != nil {
+			sugar.Errorf("unable to persist metrics for pid '%v': %v", pid, err)
+		}
+
+		// Push to live subscribers
+		s.publish(pid, *metrics)
+
+		s.evaluateAlerts(watchedProc.ProcInfo, watchedProc.series)
+
+		if watchedProc.tree != nil {
+			s.collectTree(watchedProc.tree, pid, *metrics, watchedProc.config.Smaps)
+		}
+
+		if watchedProc.threads != nil {
+			watchedProc.threads.collect(pid, metrics.Timestamp)
+		}
+
+		return nil
+	})
+
+	sugar.Debugf("process watch for '%v' exiting...", watchedProc.ProcInfo.PID)
 }
 
-func (s *Stat) getMetrics(proc *process.Process) (*ProcInfoMetrics, error) {
+// Is 'looper' still the one collecting samples for the watch?
+func (s *Stat) isCurrentLooper(watchedProc *Proc, looper *director.TimedLooper) bool {
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	return s.watched[watchedProc.ProcInfo.PID] == watchedProc && watchedProc.Looper == looper
+}
+
+// Record that the loop of the watch exited on its own; prevents
+// StopWatchProcess() from attempting to .Quit the looper (and leaking the
+// goroutine that signals it)
+func (s *Stat) setWatchErr(watchedProc *Proc, err error) {
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	watchedProc.Err = err
+}
+
+func (s *Stat) getMetrics(proc *process.Process, prev *ProcInfoMetrics, smaps bool) (*ProcInfoMetrics, error) {
 	meminfo, err := proc.MemoryInfo()
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch memory info: %v", err)
@@ -320,14 +1033,165 @@ func (s *Stat) getMetrics(proc *process.Process) (*ProcInfoMetrics, error) {
 		return nil, fmt.Errorf("unable to fetch thread count: %v", err)
 	}
 
-	return &ProcInfoMetrics{
-		RSS:       meminfo.RSS,
-		VMS:       meminfo.VMS,
-		Swap:      meminfo.Swap,
-		CPU:       percent,
-		Threads:   threads,
-		Timestamp: time.Now(),
-	}, nil
+	ctxSwitches, err := proc.NumCtxSwitches()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch context switches: %v", err)
+	}
+
+	st, err := readProcStat(proc.Pid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch page faults: %v", err)
+	}
+
+	metrics := &ProcInfoMetrics{
+		RSS:                    meminfo.RSS,
+		VMS:                    meminfo.VMS,
+		Swap:                   meminfo.Swap,
+		CPU:                    percent,
+		Threads:                threads,
+		VoluntaryCtxSwitches:   uint64(ctxSwitches.Voluntary),
+		InvoluntaryCtxSwitches: uint64(ctxSwitches.Involuntary),
+		MinorFaults:            st.MinFlt,
+		MajorFaults:            st.MajFlt,
+		Timestamp:              time.Now(),
+	}
+
+	// Not every kernel is built with schedstats
+	if wait, err := readRunQueueWait(proc.Pid); err == nil {
+		metrics.RunQueueWait = wait
+	} else {
+		sugar.Debugf("unable to fetch run queue wait for pid '%v': %v", proc.Pid, err)
+	}
+
+	if prev != nil {
+		metrics.VoluntaryCtxSwitchesDelta = delta(prev.VoluntaryCtxSwitches, metrics.VoluntaryCtxSwitches)
+		metrics.InvoluntaryCtxSwitchesDelta = delta(prev.InvoluntaryCtxSwitches, metrics.InvoluntaryCtxSwitches)
+		metrics.MinorFaultsDelta = delta(prev.MinorFaults, metrics.MinorFaults)
+		metrics.MajorFaultsDelta = delta(prev.MajorFaults, metrics.MajorFaults)
+
+		if metrics.RunQueueWait > prev.RunQueueWait {
+			metrics.RunQueueWaitDelta = metrics.RunQueueWait - prev.RunQueueWait
+		}
+	}
+
+	if fds, err := readFDs(proc.Pid); err == nil {
+		countFDs(metrics, fds)
+	} else {
+		sugar.Debugf("unable to fetch fds for pid '%v': %v", proc.Pid, err)
+	}
+
+	if soft, hard, err := readFDLimits(proc.Pid); err == nil {
+		metrics.FDSoftLimit = soft
+		metrics.FDHardLimit = hard
+	} else {
+		sugar.Debugf("unable to fetch fd limits for pid '%v': %v", proc.Pid, err)
+	}
+
+	if smaps {
+		if totals, err := readSmaps(proc.Pid); err == nil {
+			metrics.PSS = totals.PSS
+			metrics.USS = totals.PrivateClean + totals.PrivateDirty
+			metrics.SharedClean = totals.SharedClean
+			metrics.SharedDirty = totals.SharedDirty
+			metrics.Anonymous = totals.Anonymous
+		} else {
+			sugar.Debugf("unable to fetch smaps for pid '%v': %v", proc.Pid, err)
+		}
+	}
+
+	// /proc/<pid>/io is only readable by the process owner (or root) - do not
+	// throw away the rest of the sample if it's not accessible.
+	ioCounters, err := proc.IOCounters()
+	if err != nil {
+		sugar.Debugf("unable to fetch I/O counters for pid '%v': %v", proc.Pid, err)
+		return metrics, nil
+	}
+
+	metrics.ReadBytes = ioCounters.ReadBytes
+	metrics.WriteBytes = ioCounters.WriteBytes
+	metrics.ReadCount = ioCounters.ReadCount
+	metrics.WriteCount = ioCounters.WriteCount
+
+	if prev != nil {
+		elapsed := metrics.Timestamp.Sub(prev.Timestamp).Seconds()
+
+		metrics.ReadBytesPerSec = rate(prev.ReadBytes, metrics.ReadBytes, elapsed)
+		metrics.WriteBytesPerSec = rate(prev.WriteBytes, metrics.WriteBytes, elapsed)
+	}
+
+	return metrics, nil
+}
+
+// Calculate per-second rate between two cumulative counter values
+func rate(prev, cur uint64, elapsed float64) float64 {
+	// Counter went backwards (or no time has passed) - nothing sensible to report
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+
+	return float64(cur-prev) / elapsed
+}
+
+// Increase between two cumulative counter values
+func delta(prev, cur uint64) uint64 {
+	// Counter went backwards (ie. pid was re-used)
+	if cur < prev {
+		return 0
+	}
+
+	return cur - prev
+}
+
+// Move a watch whose process has exited (or can no longer be sampled) to the
+// finished watches
+func (s *Stat) finishWatch(watchedProc *Proc) {
+	pid := watchedProc.ProcInfo.PID
+
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	// Stopped by hand in the meantime
+	if s.watched[pid] != watchedProc {
+		return
+	}
+
+	delete(s.watched, pid)
+
+	watchedProc.ProcInfo.Interval = Duration(watchedProc.config.interval(s.statInterval))
+
+	s.finished[pid] = watchedProc
+
+	// Let live subscribers know that there will be no more samples
+	s.closeSubscriptions(pid)
+
+	// Nothing left to evaluate
+	s.endAlerts(pid)
+
+	// Process is still running but can not be watched - do not let the rule
+	// re-attach (and fail again) on every process list refresh
+	if watchedProc.exit.Reason == ExitReasonError && watchedProc.ProcInfo.Rule != "" {
+		s.rulesLock.Lock()
+		s.ruleIgnored[pid] = struct{}{}
+		s.rulesLock.Unlock()
+	}
+
+	sugar.Infof("watched pid '%v' is gone (%v); its history is kept until deleted", pid, watchedProc.exit.Reason)
+
+	// Do not resume this watch on restart
+	record, err := s.storage.GetWatch(pid)
+	if err != nil {
+		return
+	}
+
+	peak := watchedProc.series.peakMetrics()
+
+	record.Active = false
+	record.Exit = watchedProc.exit
+	record.Peak = &peak
+
+	if err := s.storage.SaveWatch(record); err != nil {
+		sugar.Errorf("unable to deactivate stored watch for pid '%v': %v", pid, err)
+	}
 }
 
 // Stop gathering watched for a specific process
@@ -349,18 +1213,49 @@ func (s *Stat) StopWatchProcess(pid int32) error {
 	// Only stop the looper if it hasn't already exited on its own
 	if procInfo.Err == nil {
 		procInfo.Looper.Quit()
+
+		// Stopped by hand - do not let the rule immediately re-attach
+		if procInfo.ProcInfo.Rule != "" {
+			s.rulesLock.Lock()
+			s.ruleIgnored[pid] = struct{}{}
+			s.rulesLock.Unlock()
+		}
 	}
 
 	// Remove map entry
 	delete(s.watched, pid)
 
+	// Let live subscribers know that there will be no more samples
+	s.closeSubscriptions(pid)
+
+	// Nothing left to evaluate
+	s.endAlerts(pid)
+
+	// Keep stored history around, but do not resume this watch on restart
+	record, err := s.storage.GetWatch(pid)
+	if err != nil {
+		return nil
+	}
+
+	record.Active = false
+
+	if err := s.storage.SaveWatch(record); err != nil {
+		return fmt.Errorf("unable to deactivate stored watch for pid '%v': %v", pid, err)
+	}
+
 	return nil
 }
 
-// Get statistics for a specific pid
+// Get statistics for a specific pid; falls back to stored history if the
+// pid is no longer being watched
 func (s *Stat) GetStatsForPID(pid int32, offset int) (ProcInfo, error) {
 	if !s.isWatched(pid) {
-		return ProcInfo{}, NotWatchedErr
+		// Finished watches are kept in memory even without storage
+		if history, err := s.GetHistoryForPID(pid, offset); err != NoHistoryErr {
+			return history.ProcInfo, err
+		}
+
+		return s.getStoredStatsForPID(pid, offset)
 	}
 
 	s.watchedLock.Lock()
@@ -372,19 +1267,99 @@ func (s *Stat) GetStatsForPID(pid int32, offset int) (ProcInfo, error) {
 		return ProcInfo{}, fmt.Errorf("stats no longer available for pid '%v' (bug?)", pid)
 	}
 
-	// Copy metrics (but need lock first)
-	proc.ProcInfo.MetricsLock.Lock()
-	defer proc.ProcInfo.MetricsLock.Unlock()
-
-	if len(proc.ProcInfo.Metrics) < offset {
-		return ProcInfo{}, InvalidOffsetErr
+	// Copy metrics (series handles its own locking)
+	metrics, buckets, nextOffset, err := proc.series.read(offset)
+	if err != nil {
+		return ProcInfo{}, err
 	}
 
-	metrics := make([]ProcInfoMetrics, 0)
-	metrics = append(metrics, proc.ProcInfo.Metrics[offset:]...)
+	retention := proc.series.policy
 
 	procCopy := *proc
 	procCopy.ProcInfo.Metrics = metrics
+	procCopy.ProcInfo.Buckets = buckets
+	procCopy.ProcInfo.NextOffset = nextOffset
+	procCopy.ProcInfo.Retention = &retention
+	procCopy.ProcInfo.Interval = Duration(proc.config.interval(s.statInterval))
+
+	if proc.tree != nil {
+		treeMetrics, treeBuckets, children, err := proc.tree.read(offset)
+		if err != nil {
+			return ProcInfo{}, err
+		}
+
+		procCopy.ProcInfo.TreeMetrics = treeMetrics
+		procCopy.ProcInfo.TreeBuckets = treeBuckets
+		procCopy.ProcInfo.Children = children
+	}
 
 	return procCopy.ProcInfo, nil
 }
+
+func (s *Stat) getStoredStatsForPID(pid int32, offset int) (ProcInfo, error) {
+	return StoredStatsForPID(s.storage, pid, offset)
+}
+
+// StoredStatsForPID reads stats for a (current or past) watch straight from
+// storage; used for inspecting a data dir without starting a Stat
+func StoredStatsForPID(storage Storage, pid int32, offset int) (ProcInfo, error) {
+	record, err := storage.GetWatch(pid)
+	if err != nil {
+		return ProcInfo{}, NotWatchedErr
+	}
+
+	history, err := storage.GetMetrics(pid)
+	if err != nil {
+		return ProcInfo{}, fmt.Errorf("unable to load stored metrics for pid '%v': %v", pid, err)
+	}
+
+	// Apply the same retention the watch had while it was running
+	series := newSeries(record.Config.Retention)
+
+	for _, m := range history {
+		series.append(m)
+	}
+
+	metrics, buckets, nextOffset, err := series.read(offset)
+	if err != nil {
+		return ProcInfo{}, err
+	}
+
+	return ProcInfo{
+		PID:          record.PID,
+		Name:         record.Name,
+		CmdLine:      record.CmdLine,
+		Rule:         record.Rule,
+		Interval:     Duration(record.Config.interval(StatInterval)),
+		WatchedSince: &record.StartedAt,
+		Metrics:      metrics,
+		Buckets:      buckets,
+		NextOffset:   nextOffset,
+		Retention:    &series.policy,
+	}, nil
+}
+
+// Get all actively watched processes; metrics only contain the most recent
+// sample (if one has been collected yet)
+func (s *Stat) GetWatchedProcesses() ([]ProcInfo, error) {
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	procInfos := make([]ProcInfo, 0, len(s.watched))
+
+	for _, proc := range s.watched {
+		procInfo := proc.ProcInfo
+		procInfo.Interval = Duration(proc.config.interval(s.statInterval))
+		procInfo.Metrics = make([]ProcInfoMetrics, 0, 1)
+
+		if last, ok := proc.series.last(); ok {
+			procInfo.Metrics = append(procInfo.Metrics, last)
+		}
+
+		procInfos = append(procInfos, procInfo)
+	}
+
+	sort.Slice(procInfos, func(i, j int) bool { return procInfos[i].PID < procInfos[j].PID })
+
+	return procInfos, nil
+}
@@ -0,0 +1,122 @@
+package stat
+
+import (
+	"time"
+)
+
+// Storage persists watches and their metrics so that they survive restarts
+type Storage interface {
+	// Save (or overwrite) a watch record
+	SaveWatch(record WatchRecord) error
+
+	// Fetch a single watch record; returns NotWatchedErr if there is no record
+	GetWatch(pid int32) (WatchRecord, error)
+
+	// Fetch all watch records (active and inactive)
+	GetWatches() ([]WatchRecord, error)
+
+	// Remove a watch record along with its metrics
+	DeleteWatch(pid int32) error
+
+	// Remove all metrics for a pid; used when a new watch is started for it
+	ResetMetrics(pid int32) error
+
+	// Append a single sample to a pid's metrics
+	AppendMetrics(pid int32, metrics ProcInfoMetrics) error
+
+	// Fetch all stored metrics for a pid
+	GetMetrics(pid int32) ([]ProcInfoMetrics, error)
+
+	// Save (overwrite) the full set of watch rules
+	SaveRules(rules []WatchRule) error
+
+	// Fetch all watch rules
+	GetRules() ([]WatchRule, error)
+
+	// Save (overwrite) the full set of alert rules
+	SaveAlertRules(rules []AlertRule) error
+
+	// Fetch all alert rules
+	GetAlertRules() ([]AlertRule, error)
+
+	Close() error
+}
+
+// WatchRecord is what gets persisted about a watch
+type WatchRecord struct {
+	PID     int32       `json:"pid"`
+	Name    string      `json:"name"`
+	CmdLine string      `json:"cmd_line"`
+	Config  WatchConfig `json:"config"`
+
+	// ID of the rule that started the watch (if any)
+	Rule string `json:"rule,omitempty"`
+
+	// Process create time (ms since epoch); used to tell whether a PID has
+	// been re-used by a different process after a restart
+	CreateTime int64 `json:"create_time"`
+
+	StartedAt time.Time `json:"started_at"`
+
+	// Active is false once the watch is stopped or the process has exited;
+	// the metrics remain queryable.
+	Active bool `json:"active"`
+
+	// Set once the process has exited (see Stat.GetHistoryForPID)
+	Exit *ExitInfo `json:"exit,omitempty"`
+
+	// Highest value of every metric over the lifetime of the watch; set
+	// once the process has exited (stored samples may have been evicted)
+	Peak *ProcInfoMetrics `json:"peak,omitempty"`
+}
+
+// nopStorage is used when persistence is not enabled
+type nopStorage struct{}
+
+func (n *nopStorage) SaveWatch(record WatchRecord) error {
+	return nil
+}
+
+func (n *nopStorage) GetWatch(pid int32) (WatchRecord, error) {
+	return WatchRecord{}, NotWatchedErr
+}
+
+func (n *nopStorage) GetWatches() ([]WatchRecord, error) {
+	return []WatchRecord{}, nil
+}
+
+func (n *nopStorage) DeleteWatch(pid int32) error {
+	return nil
+}
+
+func (n *nopStorage) ResetMetrics(pid int32) error {
+	return nil
+}
+
+func (n *nopStorage) AppendMetrics(pid int32, metrics ProcInfoMetrics) error {
+	return nil
+}
+
+func (n *nopStorage) GetMetrics(pid int32) ([]ProcInfoMetrics, error) {
+	return []ProcInfoMetrics{}, nil
+}
+
+func (n *nopStorage) SaveRules(rules []WatchRule) error {
+	return nil
+}
+
+func (n *nopStorage) GetRules() ([]WatchRule, error) {
+	return []WatchRule{}, nil
+}
+
+func (n *nopStorage) SaveAlertRules(rules []AlertRule) error {
+	return nil
+}
+
+func (n *nopStorage) GetAlertRules() ([]AlertRule, error) {
+	return []AlertRule{}, nil
+}
+
+func (n *nopStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,495 @@
+package stat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	watchesFile    = "watches.json"
+	rulesFile      = "rules.json"
+	alertRulesFile = "alert_rules.json"
+	metricsDir     = "metrics"
+
+	// A metrics log is compacted once it holds 1/metricsCompactSlack more
+	// samples than its watch retains
+	metricsCompactSlack = 4
+)
+
+// FileStorage is an on-disk Storage implementation.
+//
+// Layout:
+//
+//	<dir>/watches.json        - all watch records (rewritten atomically on change)
+//	<dir>/rules.json          - all watch rules (rewritten atomically on change)
+//	<dir>/alert_rules.json    - all alert rules (rewritten atomically on change)
+//	<dir>/metrics/<pid>.jsonl - append-only log of samples, one JSON object per line
+//
+// Metrics logs are bounded by the retention policy of their watch: once a log
+// holds a quarter more samples than the watch can retain (raw samples +
+// samples folded into buckets), it is rewritten with only the retained
+// samples (see compact()). Logs are streamed, never loaded as a whole.
+type FileStorage struct {
+	dir string
+
+	watches map[int32]WatchRecord
+
+	// Open append handles for metrics logs
+	files map[int32]*os.File
+
+	// Number of samples in each open metrics log
+	lines map[int32]int
+
+	lock *sync.Mutex
+}
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(filepath.Join(dir, metricsDir), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create storage dir: %v", err)
+	}
+
+	f := &FileStorage{
+		dir:     dir,
+		watches: make(map[int32]WatchRecord, 0),
+		files:   make(map[int32]*os.File, 0),
+		lines:   make(map[int32]int, 0),
+		lock:    &sync.Mutex{},
+	}
+
+	if err := f.loadWatches(); err != nil {
+		return nil, fmt.Errorf("unable to load watches: %v", err)
+	}
+
+	return f, nil
+}
+
+func (f *FileStorage) loadWatches() error {
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, watchesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	records := make([]WatchRecord, 0)
+
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		f.watches[r.PID] = r
+	}
+
+	return nil
+}
+
+// Write out all watch records. Caller must hold the lock.
+func (f *FileStorage) writeWatches() error {
+	return f.writeJSON(watchesFile, f.sortedWatches())
+}
+
+// Write to a temp file + rename so a crash never leaves a half-written file behind
+func (f *FileStorage) writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(f.dir, name+".tmp")
+
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(f.dir, name))
+}
+
+func (f *FileStorage) sortedWatches() []WatchRecord {
+	records := make([]WatchRecord, 0, len(f.watches))
+
+	for _, r := range f.watches {
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].PID < records[j].PID })
+
+	return records
+}
+
+func (f *FileStorage) metricsPath(pid int32) string {
+	return filepath.Join(f.dir, metricsDir, strconv.Itoa(int(pid))+".jsonl")
+}
+
+func (f *FileStorage) SaveWatch(record WatchRecord) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.watches[record.PID] = record
+
+	// No more samples will be appended for inactive watches
+	if !record.Active {
+		f.closeFile(record.PID)
+	}
+
+	return f.writeWatches()
+}
+
+func (f *FileStorage) GetWatch(pid int32) (WatchRecord, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	record, ok := f.watches[pid]
+	if !ok {
+		return WatchRecord{}, NotWatchedErr
+	}
+
+	return record, nil
+}
+
+func (f *FileStorage) GetWatches() ([]WatchRecord, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.sortedWatches(), nil
+}
+
+func (f *FileStorage) DeleteWatch(pid int32) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closeFile(pid)
+
+	if err := os.Remove(f.metricsPath(pid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, ok := f.watches[pid]; !ok {
+		return nil
+	}
+
+	delete(f.watches, pid)
+
+	return f.writeWatches()
+}
+
+func (f *FileStorage) ResetMetrics(pid int32) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.closeFile(pid)
+
+	if err := os.Remove(f.metricsPath(pid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStorage) AppendMetrics(pid int32, metrics ProcInfoMetrics) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	file, err := f.openFile(pid)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	f.lines[pid]++
+
+	if f.lines[pid] > f.compactThreshold(pid) {
+		return f.compact(pid)
+	}
+
+	return nil
+}
+
+// Open (or return the already open) append handle of a metrics log. Caller
+// must hold the lock.
+func (f *FileStorage) openFile(pid int32) (*os.File, error) {
+	if file, ok := f.files[pid]; ok {
+		return file, nil
+	}
+
+	// Count what is already in there so that we know when to compact
+	lines, err := f.scanMetrics(pid, func(line []byte) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(f.metricsPath(pid), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	f.files[pid] = file
+	f.lines[pid] = lines
+
+	return file, nil
+}
+
+// Max number of samples a watch can retain (see RetentionPolicy). Caller must
+// hold the lock.
+func (f *FileStorage) retainedSamples(pid int32) int {
+	policy := f.watches[pid].Config.Retention.withDefaults()
+
+	return policy.MaxSamples + policy.MaxBuckets*policy.BucketSize
+}
+
+// Number of samples a metrics log may hold before it gets compacted. Caller
+// must hold the lock.
+func (f *FileStorage) compactThreshold(pid int32) int {
+	retained := f.retainedSamples(pid)
+
+	return retained + retained/metricsCompactSlack + 1
+}
+
+// Rewrite a metrics log with only the samples its watch retains: the newest
+// retainedSamples() samples that are not older than MaxAge. The log is
+// streamed twice (once to find the newest sample, once to copy what is kept)
+// so only a single line is in memory at a time. Caller must hold the lock.
+func (f *FileStorage) compact(pid int32) error {
+	f.closeFile(pid)
+
+	var newest time.Time
+
+	lines, err := f.scanMetrics(pid, func(line []byte) error {
+		if ts, err := sampleTimestamp(line); err == nil && ts.After(newest) {
+			newest = ts
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+
+	if maxAge := f.watches[pid].Config.Retention.MaxAge; maxAge > 0 {
+		cutoff = newest.Add(-time.Duration(maxAge))
+	}
+
+	skip := lines - f.retainedSamples(pid)
+
+	tmp := f.metricsPath(pid) + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	kept := 0
+
+	_, err = f.scanMetrics(pid, func(line []byte) error {
+		if skip > 0 {
+			skip--
+			return nil
+		}
+
+		if ts, err := sampleTimestamp(line); err != nil || ts.Before(cutoff) {
+			return nil
+		}
+
+		kept++
+
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+
+		return w.WriteByte('\n')
+	})
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, f.metricsPath(pid)); err != nil {
+		return err
+	}
+
+	sugar.Debugf("compacted metrics for pid '%v' to %v samples", pid, kept)
+
+	return nil
+}
+
+// Only decodes the timestamp of a logged sample
+func sampleTimestamp(line []byte) (time.Time, error) {
+	var m struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	err := json.Unmarshal(line, &m)
+
+	return m.Timestamp, err
+}
+
+func (f *FileStorage) GetMetrics(pid int32) ([]ProcInfoMetrics, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.readMetrics(pid)
+}
+
+// Caller must hold the lock
+func (f *FileStorage) readMetrics(pid int32) ([]ProcInfoMetrics, error) {
+	metrics := make([]ProcInfoMetrics, 0)
+
+	_, err := f.scanMetrics(pid, func(line []byte) error {
+		var m ProcInfoMetrics
+
+		// A crash mid-write can leave a partial last line - skip it
+		if err := json.Unmarshal(line, &m); err != nil {
+			sugar.Warnf("skipping unreadable sample in '%v': %v", f.metricsPath(pid), err)
+			return nil
+		}
+
+		metrics = append(metrics, m)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// Call 'fn' for every line of a metrics log (a missing log is empty); returns
+// the number of lines. Caller must hold the lock.
+func (f *FileStorage) scanMetrics(pid int32, fn func(line []byte) error) (int, error) {
+	file, err := os.Open(f.metricsPath(pid))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lines := 0
+
+	for scanner.Scan() {
+		lines++
+
+		if err := fn(scanner.Bytes()); err != nil {
+			return lines, err
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func (f *FileStorage) SaveRules(rules []WatchRule) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.writeJSON(rulesFile, rules)
+}
+
+func (f *FileStorage) GetRules() ([]WatchRule, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	rules := make([]WatchRule, 0)
+
+	if err := f.readJSON(rulesFile, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (f *FileStorage) SaveAlertRules(rules []AlertRule) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.writeJSON(alertRulesFile, rules)
+}
+
+func (f *FileStorage) GetAlertRules() ([]AlertRule, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	rules := make([]AlertRule, 0)
+
+	if err := f.readJSON(alertRulesFile, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Read a file written by writeJSON(); a missing file leaves 'v' untouched
+func (f *FileStorage) readJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (f *FileStorage) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for pid := range f.files {
+		f.closeFile(pid)
+	}
+
+	return nil
+}
+
+// Caller must hold the lock
+func (f *FileStorage) closeFile(pid int32) {
+	file, ok := f.files[pid]
+	if !ok {
+		return
+	}
+
+	if err := file.Close(); err != nil {
+		sugar.Errorf("unable to close metrics file for pid '%v': %v", pid, err)
+	}
+
+	delete(f.files, pid)
+	delete(f.lines, pid)
+}
@@ -0,0 +1,115 @@
+package stat
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileStorageCompactsMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pidstat-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer storage.Close()
+
+	// Retains 4 raw samples + 2 buckets of 3 samples = 10 samples
+	policy := RetentionPolicy{MaxSamples: 4, BucketSize: 3, MaxBuckets: 2}
+
+	if err := storage.SaveWatch(WatchRecord{PID: 1, Config: WatchConfig{Retention: policy}, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(0, 0)
+
+	for i := 0; i < 100; i++ {
+		m := ProcInfoMetrics{RSS: uint64(i), Timestamp: start.Add(time.Duration(i) * time.Second)}
+
+		if err := storage.AppendMetrics(1, m); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := storage.lines[1]; n > storage.compactThreshold(1) {
+			t.Fatalf("metrics log holds %v samples after %v appends", n, i+1)
+		}
+	}
+
+	metrics, err := storage.GetMetrics(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if threshold := storage.compactThreshold(1); len(metrics) < 10 || len(metrics) > threshold {
+		t.Fatalf("expected between 10 and %v stored samples, got %v", threshold, len(metrics))
+	}
+
+	if last := metrics[len(metrics)-1].RSS; last != 99 {
+		t.Errorf("expected newest sample to be kept, got rss %v", last)
+	}
+
+	// Re-opening the log (ie. after a restart) picks up the sample count
+	storage.Close()
+
+	if err := storage.AppendMetrics(1, ProcInfoMetrics{RSS: 100, Timestamp: start.Add(100 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if storage.lines[1] != len(metrics)+1 {
+		t.Errorf("expected %v samples after re-open, got %v", len(metrics)+1, storage.lines[1])
+	}
+}
+
+func TestFileStorageCompactionDropsOldSamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pidstat-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer storage.Close()
+
+	policy := RetentionPolicy{MaxSamples: 4, BucketSize: 3, MaxBuckets: 2, MaxAge: Duration(5 * time.Second)}
+
+	if err := storage.SaveWatch(WatchRecord{PID: 1, Config: WatchConfig{Retention: policy}, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(0, 0)
+
+	// Compacted on the last append; everything more than 5s older is dropped
+	last := storage.compactThreshold(1)
+
+	for i := 0; i <= last; i++ {
+		if err := storage.AppendMetrics(1, ProcInfoMetrics{Timestamp: start.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics, err := storage.GetMetrics(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != 6 {
+		t.Fatalf("expected 6 samples, got %v", len(metrics))
+	}
+
+	if first := metrics[0].Timestamp; !first.Equal(start.Add(time.Duration(last-5) * time.Second)) {
+		t.Errorf("expected oldest sample at %vs, got %v", last-5, first.Sub(start))
+	}
+}
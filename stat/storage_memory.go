@@ -0,0 +1,156 @@
+package stat
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// 1 day worth of samples at the default StatInterval
+	DefaultMemoryStorageMaxSamples = 17280
+)
+
+// MemoryStorage is an in-memory Storage implementation; used where history is
+// needed without a data dir (ie. for hosts pushing to a central server). Only
+// the newest 'maxSamples' samples are kept per pid.
+type MemoryStorage struct {
+	maxSamples int
+
+	watches    map[int32]WatchRecord
+	metrics    map[int32][]ProcInfoMetrics
+	rules      []WatchRule
+	alertRules []AlertRule
+
+	lock *sync.Mutex
+}
+
+func NewMemoryStorage(maxSamples int) *MemoryStorage {
+	if maxSamples <= 0 {
+		maxSamples = DefaultMemoryStorageMaxSamples
+	}
+
+	return &MemoryStorage{
+		maxSamples: maxSamples,
+		watches:    make(map[int32]WatchRecord, 0),
+		metrics:    make(map[int32][]ProcInfoMetrics, 0),
+		rules:      make([]WatchRule, 0),
+		alertRules: make([]AlertRule, 0),
+		lock:       &sync.Mutex{},
+	}
+}
+
+func (m *MemoryStorage) SaveWatch(record WatchRecord) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.watches[record.PID] = record
+
+	return nil
+}
+
+func (m *MemoryStorage) GetWatch(pid int32) (WatchRecord, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	record, ok := m.watches[pid]
+	if !ok {
+		return WatchRecord{}, NotWatchedErr
+	}
+
+	return record, nil
+}
+
+func (m *MemoryStorage) GetWatches() ([]WatchRecord, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	records := make([]WatchRecord, 0, len(m.watches))
+
+	for _, r := range m.watches {
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].PID < records[j].PID })
+
+	return records, nil
+}
+
+func (m *MemoryStorage) DeleteWatch(pid int32) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.watches, pid)
+	delete(m.metrics, pid)
+
+	return nil
+}
+
+func (m *MemoryStorage) ResetMetrics(pid int32) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.metrics, pid)
+
+	return nil
+}
+
+func (m *MemoryStorage) AppendMetrics(pid int32, metrics ProcInfoMetrics) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	samples := append(m.metrics[pid], metrics)
+
+	if len(samples) > m.maxSamples {
+		samples = samples[len(samples)-m.maxSamples:]
+	}
+
+	m.metrics[pid] = samples
+
+	return nil
+}
+
+func (m *MemoryStorage) GetMetrics(pid int32) ([]ProcInfoMetrics, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	metrics := make([]ProcInfoMetrics, len(m.metrics[pid]))
+	copy(metrics, m.metrics[pid])
+
+	return metrics, nil
+}
+
+func (m *MemoryStorage) SaveRules(rules []WatchRule) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.rules = rules
+
+	return nil
+}
+
+func (m *MemoryStorage) GetRules() ([]WatchRule, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.rules, nil
+}
+
+func (m *MemoryStorage) SaveAlertRules(rules []AlertRule) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.alertRules = rules
+
+	return nil
+}
+
+func (m *MemoryStorage) GetAlertRules() ([]AlertRule, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.alertRules, nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package stat
+
+import (
+	"sync/atomic"
+)
+
+const (
+	// Number of samples buffered per subscriber before the oldest ones get dropped
+	SubscriptionBufferSize = 64
+)
+
+// Subscription receives every new sample collected for a watched pid. C is
+// closed once the watch ends (or the subscription is cancelled).
+//
+// Slow consumers never block collection: once the buffer is full, the oldest
+// buffered sample is dropped to make room (see Dropped()).
+type Subscription struct {
+	PID int32
+	C   <-chan ProcInfoMetrics
+
+	ch      chan ProcInfoMetrics
+	dropped uint64
+}
+
+// Dropped returns the number of samples that were dropped because the
+// subscriber was not keeping up
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Deliver sample without ever blocking the publisher
+func (s *Subscription) send(m ProcInfoMetrics) {
+	select {
+	case s.ch <- m:
+		return
+	default:
+	}
+
+	// Buffer is full - make room by dropping the oldest sample
+	select {
+	case <-s.ch:
+		atomic.AddUint64(&s.dropped, 1)
+	default:
+	}
+
+	select {
+	case s.ch <- m:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Subscribe to new samples for a watched pid
+func (s *Stat) Subscribe(pid int32) (*Subscription, error) {
+	// Lock order: watchedLock -> subscribersLock (same as StopWatchProcess)
+	s.watchedLock.Lock()
+	defer s.watchedLock.Unlock()
+
+	if _, ok := s.watched[pid]; !ok {
+		return nil, NotWatchedErr
+	}
+
+	ch := make(chan ProcInfoMetrics, SubscriptionBufferSize)
+
+	sub := &Subscription{
+		PID: pid,
+		C:   ch,
+		ch:  ch,
+	}
+
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	if _, ok := s.subscribers[pid]; !ok {
+		s.subscribers[pid] = make(map[*Subscription]struct{}, 0)
+	}
+
+	s.subscribers[pid][sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Unsubscribe cancels a subscription; safe to call after the watch has ended
+func (s *Stat) Unsubscribe(sub *Subscription) {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	subs, ok := s.subscribers[sub.PID]
+	if !ok {
+		return
+	}
+
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	close(sub.ch)
+
+	if len(subs) == 0 {
+		delete(s.subscribers, sub.PID)
+	}
+}
+
+func (s *Stat) publish(pid int32, m ProcInfoMetrics) {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	for sub := range s.subscribers[pid] {
+		sub.send(m)
+	}
+}
+
+// Close out all subscriptions for a pid (watch has ended)
+func (s *Stat) closeSubscriptions(pid int32) {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+
+	for sub := range s.subscribers[pid] {
+		close(sub.ch)
+	}
+
+	delete(s.subscribers, pid)
+}
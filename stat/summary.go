@@ -0,0 +1,270 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary contains summary statistics for a series of samples
+type Summary struct {
+	Samples  int       `json:"samples"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration Duration  `json:"duration"`
+
+	// Keyed by the JSON name of the sample field (ie. "rss", "cpu")
+	Metrics map[string]MetricSummary `json:"metrics"`
+}
+
+// MetricSummary contains summary statistics for a single sample field
+type MetricSummary struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+	Last   float64 `json:"last"`
+}
+
+// MetricNames returns the JSON names of all numeric sample fields (in field order)
+func MetricNames() []string {
+	names := make([]string, 0)
+
+	sampleType := reflect.TypeOf(ProcInfoMetrics{})
+	sampleValue := reflect.ValueOf(ProcInfoMetrics{})
+
+	for i := 0; i < sampleType.NumField(); i++ {
+		if _, ok := numericValue(sampleValue.Field(i)); !ok {
+			continue
+		}
+
+		names = append(names, strings.Split(sampleType.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	return names
+}
+
+// Summarize calculates summary statistics for every numeric sample field
+func Summarize(metrics []ProcInfoMetrics) Summary {
+	return SummarizeWithBuckets(metrics, nil)
+}
+
+// SummarizeWithBuckets is Summarize for a (partially) downsampled series:
+// every bucket counts as 'Samples' samples at its average and its min/max are
+// taken into account for Min/Max. The mean is exact; stddev and percentiles
+// are approximations as the spread within a bucket is lost. 'buckets' must
+// precede 'metrics' (as returned by a read at offset 0).
+func SummarizeWithBuckets(metrics []ProcInfoMetrics, buckets []MetricsBucket) Summary {
+	summary := Summary{
+		Samples: len(metrics),
+		Metrics: make(map[string]MetricSummary, 0),
+	}
+
+	for _, b := range buckets {
+		summary.Samples += b.Samples
+	}
+
+	if len(metrics) == 0 && len(buckets) == 0 {
+		return summary
+	}
+
+	if len(buckets) > 0 {
+		summary.Start = buckets[0].Start
+		summary.End = buckets[len(buckets)-1].End
+	} else {
+		summary.Start = metrics[0].Timestamp
+	}
+
+	if len(metrics) > 0 {
+		summary.End = metrics[len(metrics)-1].Timestamp
+	}
+
+	summary.Duration = Duration(summary.End.Sub(summary.Start))
+
+	for _, name := range MetricNames() {
+		values := make([]weightedValue, 0, len(buckets)+len(metrics))
+
+		var lowest, highest float64
+
+		for i, b := range buckets {
+			avg, _ := MetricValue(b.Avg, name)
+			min, _ := MetricValue(b.Min, name)
+			max, _ := MetricValue(b.Max, name)
+
+			if i == 0 || min < lowest {
+				lowest = min
+			}
+
+			if i == 0 || max > highest {
+				highest = max
+			}
+
+			values = append(values, weightedValue{value: avg, weight: b.Samples})
+		}
+
+		for _, m := range metrics {
+			v, _ := MetricValue(m, name)
+			values = append(values, weightedValue{value: v, weight: 1})
+		}
+
+		s := summarizeValues(values)
+
+		if len(buckets) > 0 {
+			s.Min = math.Min(s.Min, lowest)
+			s.Max = math.Max(s.Max, highest)
+		}
+
+		summary.Metrics[name] = s
+	}
+
+	return summary
+}
+
+// A value that stands for 'weight' samples (a bucket average or a raw sample)
+type weightedValue struct {
+	value  float64
+	weight int
+}
+
+func summarizeValues(values []weightedValue) MetricSummary {
+	s := MetricSummary{
+		Last: values[len(values)-1].value,
+	}
+
+	sorted := make([]weightedValue, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+
+	s.Min = sorted[0].value
+	s.Max = sorted[len(sorted)-1].value
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P99 = percentile(sorted, 99)
+
+	var sum float64
+	var count int
+
+	for _, v := range values {
+		sum += v.value * float64(v.weight)
+		count += v.weight
+	}
+
+	s.Mean = sum / float64(count)
+
+	var squares float64
+
+	for _, v := range values {
+		squares += (v.value - s.Mean) * (v.value - s.Mean) * float64(v.weight)
+	}
+
+	// Population stddev - the samples are all there is
+	s.Stddev = math.Sqrt(squares / float64(count))
+
+	return s
+}
+
+// Linearly interpolated percentile of already sorted values (as if every
+// value was repeated 'weight' times)
+func percentile(sorted []weightedValue, p float64) float64 {
+	var count int
+
+	for _, v := range sorted {
+		count += v.weight
+	}
+
+	rank := p / 100 * float64(count-1)
+
+	lower := valueAt(sorted, int(math.Floor(rank)))
+	upper := valueAt(sorted, int(math.Ceil(rank)))
+
+	return lower + (upper-lower)*(rank-math.Floor(rank))
+}
+
+// Value at position 'n' of sorted values expanded by their weight
+func valueAt(sorted []weightedValue, n int) float64 {
+	for _, v := range sorted {
+		if n < v.weight {
+			return v.value
+		}
+
+		n -= v.weight
+	}
+
+	return sorted[len(sorted)-1].value
+}
+
+// Get summary statistics for a (current or stored) watch; samples outside of
+// [from, to] are ignored (zero times mean unbounded). Covers everything the
+// watch retains: raw samples and downsampled buckets (a bucket counts if its
+// midpoint is within [from, to]).
+func (s *Stat) GetSummaryForPID(pid int32, from, to time.Time) (Summary, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return Summary{}, fmt.Errorf("'to' (%v) is before 'from' (%v)", to, from)
+	}
+
+	metrics, buckets, err := s.getSummarySamples(pid)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return SummarizeWindow(metrics, buckets, from, to), nil
+}
+
+// SummarizeWindow is SummarizeWithBuckets for the samples within [from, to]
+// (zero times mean unbounded); a bucket counts if its midpoint is within the
+// window
+func SummarizeWindow(metrics []ProcInfoMetrics, buckets []MetricsBucket, from, to time.Time) Summary {
+	inWindow := func(t time.Time) bool {
+		return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+	}
+
+	bucketWindow := make([]MetricsBucket, 0, len(buckets))
+
+	for _, b := range buckets {
+		if inWindow(b.Avg.Timestamp) {
+			bucketWindow = append(bucketWindow, b)
+		}
+	}
+
+	window := make([]ProcInfoMetrics, 0, len(metrics))
+
+	for _, m := range metrics {
+		if inWindow(m.Timestamp) {
+			window = append(window, m)
+		}
+	}
+
+	return SummarizeWithBuckets(window, bucketWindow)
+}
+
+// Retained samples + buckets of a watch; watches that are only on disk (ie.
+// stopped before a restart) are loaded from storage
+func (s *Stat) getSummarySamples(pid int32) ([]ProcInfoMetrics, []MetricsBucket, error) {
+	s.watchedLock.Lock()
+
+	proc, ok := s.watched[pid]
+	if !ok {
+		proc, ok = s.finished[pid]
+	}
+
+	s.watchedLock.Unlock()
+
+	if ok {
+		metrics, buckets, _, err := proc.series.read(0)
+
+		return metrics, buckets, err
+	}
+
+	procInfo, err := StoredStatsForPID(s.storage, pid, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return procInfo.Metrics, procInfo.Buckets, nil
+}
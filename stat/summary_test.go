@@ -0,0 +1,71 @@
+package stat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarizeWithBuckets(t *testing.T) {
+	// 0-5 are folded into 2 buckets of 3 samples (avg 1 and 4), 6-9 stay raw
+	s := newTestSeries(RetentionPolicy{MaxSamples: 4, BucketSize: 3}, 10)
+
+	metrics, buckets, _, err := s.read(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		summary Summary
+		samples int
+		want    MetricSummary
+	}{
+		{
+			name:    "raw samples only",
+			summary: Summarize(metrics),
+			samples: 4,
+			want:    MetricSummary{Min: 6, Max: 9, Mean: 7.5, Stddev: math.Sqrt(1.25), P50: 7.5, P90: 8.7, P99: 8.97, Last: 9},
+		},
+		{
+			// Buckets count as 3 samples at their average: 1 1 1 4 4 4 6 7 8 9
+			name:    "buckets + raw samples",
+			summary: SummarizeWithBuckets(metrics, buckets),
+			samples: 10,
+			want:    MetricSummary{Min: 0, Max: 9, Mean: 4.5, Stddev: math.Sqrt(7.85), P50: 4, P90: 8.1, P99: 8.91, Last: 9},
+		},
+		{
+			name:    "buckets only",
+			summary: SummarizeWithBuckets(nil, buckets),
+			samples: 6,
+			want:    MetricSummary{Min: 0, Max: 5, Mean: 2.5, Stddev: 1.5, P50: 2.5, P90: 4, P99: 4, Last: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.summary.Samples != tt.samples {
+				t.Errorf("samples = %v, want %v", tt.summary.Samples, tt.samples)
+			}
+
+			got := tt.summary.Metrics["rss"]
+
+			for _, v := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"min", got.Min, tt.want.Min},
+				{"max", got.Max, tt.want.Max},
+				{"mean", got.Mean, tt.want.Mean},
+				{"stddev", got.Stddev, tt.want.Stddev},
+				{"p50", got.P50, tt.want.P50},
+				{"p90", got.P90, tt.want.P90},
+				{"p99", got.P99, tt.want.P99},
+				{"last", got.Last, tt.want.Last},
+			} {
+				if math.Abs(v.got-v.want) > 1e-9 {
+					t.Errorf("%v = %v, want %v", v.name, v.got, v.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package stat
+
+import (
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ThreadsNotEnabledErr = errors.New("watch does not collect per-thread metrics")
+)
+
+// ThreadInfo is a single thread of a watched process with its samples
+type ThreadInfo struct {
+	TID int32 `json:"tid"`
+
+	// comm of the thread as of the last sample (threads can rename themselves)
+	Name string `json:"name"`
+
+	Metrics []ThreadMetrics `json:"metrics"`
+}
+
+// ThreadMetrics is a single sample of a thread
+type ThreadMetrics struct {
+	// R, S, D, Z, T, ...
+	State string `json:"state"`
+
+	// Cumulative CPU time (seconds)
+	UserTime   float64 `json:"user_time"`
+	SystemTime float64 `json:"system_time"`
+
+	// CPU usage since the previous sample in percent (100 == one full core)
+	CPU float64 `json:"cpu"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// threadTracker samples every thread of a watched process ("threads"
+// watches). Threads are re-discovered on every tick; threads that exit are
+// dropped along with their samples.
+type threadTracker struct {
+	// Only MaxSamples applies (per thread)
+	policy RetentionPolicy
+
+	threads map[int32]*trackedThread
+
+	lock *sync.Mutex
+}
+
+type trackedThread struct {
+	info ThreadInfo
+
+	// Tells a re-used tid apart from the thread that had it before
+	startTime time.Time
+}
+
+func newThreadTracker(policy RetentionPolicy) *threadTracker {
+	return &threadTracker{
+		policy:  policy.withDefaults(),
+		threads: make(map[int32]*trackedThread, 0),
+		lock:    &sync.Mutex{},
+	}
+}
+
+// Tids of all threads of a process, from /proc/<pid>/task
+func threadIDs(pid int32) ([]int32, error) {
+	entries, err := ioutil.ReadDir(procPath(strconv.Itoa(int(pid)), "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	tids := make([]int32, 0, len(entries))
+
+	for _, entry := range entries {
+		tid, err := strconv.ParseInt(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		tids = append(tids, int32(tid))
+	}
+
+	return tids, nil
+}
+
+// Collect a sample for every current thread of pid
+func (t *threadTracker) collect(pid int32, now time.Time) {
+	tids, err := threadIDs(pid)
+	if err != nil {
+		sugar.Debugf("unable to list threads of pid '%v': %v", pid, err)
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	seen := make(map[int32]struct{}, len(tids))
+
+	for _, tid := range tids {
+		st, err := readTaskStat(pid, tid)
+		if err != nil {
+			// Exited in the meantime
+			continue
+		}
+
+		seen[tid] = struct{}{}
+
+		thread, ok := t.threads[tid]
+		if !ok || !thread.startTime.Equal(st.StartTime) {
+			thread = &trackedThread{
+				info: ThreadInfo{
+					TID:     tid,
+					Metrics: make([]ThreadMetrics, 0),
+				},
+				startTime: st.StartTime,
+			}
+
+			t.threads[tid] = thread
+		}
+
+		metrics := ThreadMetrics{
+			State:      st.State,
+			UserTime:   st.UTime,
+			SystemTime: st.STime,
+			Timestamp:  now,
+		}
+
+		if n := len(thread.info.Metrics); n > 0 {
+			prev := thread.info.Metrics[n-1]
+
+			elapsed := now.Sub(prev.Timestamp).Seconds()
+			used := (metrics.UserTime + metrics.SystemTime) - (prev.UserTime + prev.SystemTime)
+
+			if elapsed > 0 && used > 0 {
+				metrics.CPU = used / elapsed * 100
+			}
+		}
+
+		thread.info.Name = st.Comm
+		thread.info.Metrics = append(thread.info.Metrics, metrics)
+
+		if excess := len(thread.info.Metrics) - t.policy.MaxSamples; excess > 0 {
+			thread.info.Metrics = thread.info.Metrics[excess:]
+		}
+	}
+
+	for tid := range t.threads {
+		if _, ok := seen[tid]; !ok {
+			delete(t.threads, tid)
+		}
+	}
+}
+
+// All current threads (sorted by tid) with their retained samples
+func (t *threadTracker) read() []ThreadInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	threads := make([]ThreadInfo, 0, len(t.threads))
+
+	for _, thread := range t.threads {
+		info := thread.info
+		info.Metrics = append([]ThreadMetrics(nil), thread.info.Metrics...)
+
+		threads = append(threads, info)
+	}
+
+	sort.Slice(threads, func(i, j int) bool { return threads[i].TID < threads[j].TID })
+
+	return threads
+}
+
+// Get every thread of a watch with per-thread metrics (see WatchConfig.Threads);
+// finished watches return the threads as of their last sample
+func (s *Stat) GetThreadsForPID(pid int32) ([]ThreadInfo, error) {
+	s.watchedLock.Lock()
+
+	proc, ok := s.watched[pid]
+	if !ok {
+		proc, ok = s.finished[pid]
+	}
+
+	s.watchedLock.Unlock()
+
+	if !ok {
+		return nil, NotWatchedErr
+	}
+
+	if proc.threads == nil {
+		return nil, ThreadsNotEnabledErr
+	}
+
+	return proc.threads.read(), nil
+}
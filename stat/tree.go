@@ -0,0 +1,371 @@
+package stat
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// processTree tracks all descendants of a watched process ("tree" watches).
+// Children are re-discovered (via ppid) on every tick; children that exit are
+// dropped along with their series.
+type processTree struct {
+	policy RetentionPolicy
+
+	children map[int32]*treeChild
+
+	// Summed samples of the root + all of its children; one per tick
+	sum *series
+
+	// Number of root samples collected before the tree started being tracked
+	// (restored watches); used to line up offsets with the root series
+	base int
+
+	lock *sync.Mutex
+}
+
+type treeChild struct {
+	ProcInfo   ProcInfo
+	Process    *process.Process
+	createTime int64
+	series     *series
+}
+
+func newProcessTree(policy RetentionPolicy, base int) *processTree {
+	return &processTree{
+		policy:   policy,
+		children: make(map[int32]*treeChild, 0),
+		sum:      newSeries(policy),
+		base:     base,
+		lock:     &sync.Mutex{},
+	}
+}
+
+// Parent -> children of every process on the host; shared by all tree watches
+// and rebuilt at most once per MinStatInterval. Only used if the kernel does
+// not expose /proc/<pid>/task/<tid>/children (CONFIG_PROC_CHILDREN).
+type childIndex struct {
+	children map[int32][]int32
+	builtAt  time.Time
+
+	lock *sync.Mutex
+}
+
+func newChildIndex() *childIndex {
+	return &childIndex{
+		lock: &sync.Mutex{},
+	}
+}
+
+// The returned map is shared - do not modify it
+func (c *childIndex) get() (map[int32][]int32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.children != nil && time.Since(c.builtAt) < MinStatInterval {
+		return c.children, nil
+	}
+
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int32][]int32, 0)
+
+	for _, pid := range pids {
+		st, err := readProcStat(pid)
+		if err != nil {
+			// Exited in the meantime
+			continue
+		}
+
+		children[st.PPID] = append(children[st.PPID], pid)
+	}
+
+	c.children = children
+	c.builtAt = time.Now()
+
+	return children, nil
+}
+
+var (
+	childrenFilesOnce sync.Once
+	childrenFiles     bool
+)
+
+// Whether the kernel exposes /proc/<pid>/task/<tid>/children
+func hasChildrenFiles() bool {
+	childrenFilesOnce.Do(func() {
+		pid := strconv.Itoa(os.Getpid())
+
+		_, err := os.Stat(procPath(pid, "task", pid, "children"))
+		childrenFiles = err == nil
+	})
+
+	return childrenFiles
+}
+
+// Direct children of pid (of all of its threads)
+func readChildren(pid int32) ([]int32, error) {
+	tids, err := threadIDs(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]int32, 0)
+
+	for _, tid := range tids {
+		data, err := ioutil.ReadFile(procPath(strconv.Itoa(int(pid)), "task", strconv.Itoa(int(tid)), "children"))
+		if err != nil {
+			// Thread exited in the meantime
+			continue
+		}
+
+		for _, field := range strings.Fields(string(data)) {
+			child, err := strconv.ParseInt(field, 10, 32)
+			if err != nil {
+				continue
+			}
+
+			children = append(children, int32(child))
+		}
+	}
+
+	return children, nil
+}
+
+// Find all (transitive) children of root; only walks the tree of root if the
+// kernel allows it, otherwise uses the (shared) index of the whole host
+func (s *Stat) descendants(root int32) (map[int32]struct{}, error) {
+	childrenOf := readChildren
+
+	if !hasChildrenFiles() {
+		index, err := s.childIndex.get()
+		if err != nil {
+			return nil, err
+		}
+
+		childrenOf = func(pid int32) ([]int32, error) {
+			return index[pid], nil
+		}
+	}
+
+	found := make(map[int32]struct{}, 0)
+	queue := []int32{root}
+
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+
+		children, err := childrenOf(pid)
+		if err != nil {
+			// Exited in the meantime
+			continue
+		}
+
+		for _, child := range children {
+			if _, ok := found[child]; ok {
+				continue
+			}
+
+			found[child] = struct{}{}
+			queue = append(queue, child)
+		}
+	}
+
+	return found, nil
+}
+
+// Refresh the set of children, collect a sample for each of them and append
+// the summed sample for the whole tree
+func (s *Stat) collectTree(tree *processTree, root int32, rootMetrics ProcInfoMetrics, smaps bool) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	pids, err := s.descendants(root)
+	if err != nil {
+		sugar.Errorf("unable to discover children of pid '%v': %v", root, err)
+		pids = make(map[int32]struct{}, 0)
+	}
+
+	// Forget children that have exited (or whose pid was re-used)
+	for pid, child := range tree.children {
+		if _, ok := pids[pid]; !ok {
+			delete(tree.children, pid)
+			continue
+		}
+
+		if createTime, err := child.Process.CreateTime(); err != nil || createTime != child.createTime {
+			delete(tree.children, pid)
+		}
+	}
+
+	sum := ProcInfoMetrics{
+		RSS:              rootMetrics.RSS,
+		VMS:              rootMetrics.VMS,
+		Swap:             rootMetrics.Swap,
+		CPU:              rootMetrics.CPU,
+		Threads:          rootMetrics.Threads,
+		ReadBytesPerSec:  rootMetrics.ReadBytesPerSec,
+		WriteBytesPerSec: rootMetrics.WriteBytesPerSec,
+		Timestamp:        rootMetrics.Timestamp,
+
+		VoluntaryCtxSwitchesDelta:   rootMetrics.VoluntaryCtxSwitchesDelta,
+		InvoluntaryCtxSwitchesDelta: rootMetrics.InvoluntaryCtxSwitchesDelta,
+		MinorFaultsDelta:            rootMetrics.MinorFaultsDelta,
+		MajorFaultsDelta:            rootMetrics.MajorFaultsDelta,
+		RunQueueWaitDelta:           rootMetrics.RunQueueWaitDelta,
+
+		// Unlike RSS, these add up without counting shared pages twice
+		PSS: rootMetrics.PSS,
+		USS: rootMetrics.USS,
+
+		FDs:          rootMetrics.FDs,
+		FileFDs:      rootMetrics.FileFDs,
+		SocketFDs:    rootMetrics.SocketFDs,
+		PipeFDs:      rootMetrics.PipeFDs,
+		AnonInodeFDs: rootMetrics.AnonInodeFDs,
+	}
+
+	for pid := range pids {
+		child, ok := tree.children[pid]
+		if !ok {
+			child, err = newTreeChild(pid, tree.policy)
+			if err != nil {
+				sugar.Debugf("unable to track child '%v' of pid '%v': %v", pid, root, err)
+				continue
+			}
+
+			tree.children[pid] = child
+		}
+
+		var prev *ProcInfoMetrics
+
+		if last, ok := child.series.last(); ok {
+			prev = &last
+		}
+
+		metrics, err := s.getMetrics(child.Process, prev, smaps)
+		if err != nil {
+			// Most likely exited in the meantime; gets cleaned up next tick
+			sugar.Debugf("unable to fetch metrics for child '%v' of pid '%v': %v", pid, root, err)
+			continue
+		}
+
+		child.series.append(*metrics)
+
+		sum.RSS += metrics.RSS
+		sum.VMS += metrics.VMS
+		sum.Swap += metrics.Swap
+		sum.CPU += metrics.CPU
+		sum.Threads += metrics.Threads
+		sum.ReadBytesPerSec += metrics.ReadBytesPerSec
+		sum.WriteBytesPerSec += metrics.WriteBytesPerSec
+		sum.VoluntaryCtxSwitchesDelta += metrics.VoluntaryCtxSwitchesDelta
+		sum.InvoluntaryCtxSwitchesDelta += metrics.InvoluntaryCtxSwitchesDelta
+		sum.MinorFaultsDelta += metrics.MinorFaultsDelta
+		sum.MajorFaultsDelta += metrics.MajorFaultsDelta
+		sum.RunQueueWaitDelta += metrics.RunQueueWaitDelta
+		sum.PSS += metrics.PSS
+		sum.USS += metrics.USS
+		sum.FDs += metrics.FDs
+		sum.FileFDs += metrics.FileFDs
+		sum.SocketFDs += metrics.SocketFDs
+		sum.PipeFDs += metrics.PipeFDs
+		sum.AnonInodeFDs += metrics.AnonInodeFDs
+	}
+
+	tree.sum.append(sum)
+}
+
+func newTreeChild(pid int32, policy RetentionPolicy) (*treeChild, error) {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	procInfo, err := newProcInfo(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	createTime, err := proc.CreateTime()
+	if err != nil {
+		return nil, err
+	}
+
+	procInfo.Watched = true
+
+	return &treeChild{
+		ProcInfo:   procInfo,
+		Process:    proc,
+		createTime: createTime,
+		series:     newSeries(policy),
+	}, nil
+}
+
+// Summed samples starting at root series offset + the samples every current
+// child collected during the same ticks (all retained samples + buckets of
+// the sum and every child if 'offset' is 0 or has been evicted)
+func (t *processTree) read(offset int) ([]ProcInfoMetrics, []MetricsBucket, []ProcInfo, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	offset -= t.base
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	sum, sumBuckets, _, err := t.sum.read(offset)
+	if err == InvalidOffsetErr {
+		// Root sample for this tick is in, summed one is not (yet)
+		sum = make([]ProcInfoMetrics, 0)
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+
+	full := offset == 0 || sumBuckets != nil
+
+	children := make([]ProcInfo, 0, len(t.children))
+
+	for _, child := range t.children {
+		procInfo := child.ProcInfo
+
+		metrics, buckets, _, err := child.series.read(0)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if full {
+			procInfo.Buckets = buckets
+		} else {
+			// Children are sampled right after the root, so a child sample
+			// belongs to the tick of the latest summed sample before it
+			newer := make([]ProcInfoMetrics, 0, len(sum))
+
+			for _, m := range metrics {
+				if len(sum) > 0 && !m.Timestamp.Before(sum[0].Timestamp) {
+					newer = append(newer, m)
+				}
+			}
+
+			metrics = newer
+		}
+
+		procInfo.Metrics = metrics
+
+		children = append(children, procInfo)
+	}
+
+	sort.Slice(children, func(i, j int) bool { return children[i].PID < children[j].PID })
+
+	return sum, sumBuckets, children, nil
+}
@@ -1,15 +1,33 @@
 package util
 
 import (
+	"sort"
+	"sync"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-// CreateLogger creates either a dev or prod zap logger
+var (
+	// Core shared by every logger created via CreateLogger (swapped by SetLoggerMode)
+	baseCore       zapcore.Core
+	baseProd       bool
+	baseGeneration int
+	baseLock       = &sync.RWMutex{}
+)
+
+func init() {
+	if err := SetLoggerMode(false); err != nil {
+		panic(err)
+	}
+}
+
+// SetLoggerMode switches every logger (including ones that already exist) to
+// either dev or prod mode
 //
 // Dev logger: level = DEBUG, colored output, no sampling, stack traces incl. for WARN+ messages
 // Prod logger: level = INFO, JSON output to stderr, sampling, no stack traces
-func CreateLogger(prod bool, fields map[string]interface{}) (*zap.Logger, error) {
+func SetLoggerMode(prod bool) error {
 	var loggerConfig zap.Config
 
 	if prod {
@@ -19,12 +37,106 @@ func CreateLogger(prod bool, fields map[string]interface{}) (*zap.Logger, error)
 		loggerConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
 
-	loggerConfig.InitialFields = fields
-
 	logger, err := loggerConfig.Build()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	baseLock.Lock()
+	defer baseLock.Unlock()
+
+	baseCore = logger.Core()
+	baseProd = prod
+	baseGeneration++
+
+	return nil
+}
+
+// CreateLogger creates a zap logger that follows the mode set via SetLoggerMode
+// (dev by default)
+func CreateLogger(fields map[string]interface{}) (*zap.Logger, error) {
+	keys := make([]string, 0, len(fields))
+
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	zapFields := make([]zapcore.Field, 0, len(keys))
+
+	for _, k := range keys {
+		zapFields = append(zapFields, zap.Any(k, fields[k]))
+	}
+
+	core := &switchCore{
+		fields: zapFields,
+		lock:   &sync.Mutex{},
+	}
+
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.LevelEnablerFunc(stacktraceEnabled))), nil
+}
+
+func stacktraceEnabled(level zapcore.Level) bool {
+	baseLock.RLock()
+	defer baseLock.RUnlock()
+
+	if baseProd {
+		return level >= zapcore.ErrorLevel
+	}
+
+	return level >= zapcore.WarnLevel
+}
+
+// switchCore delegates to the current base core (+ its own fields)
+type switchCore struct {
+	fields []zapcore.Field
+
+	// Base core with fields applied; rebuilt when the base core is swapped
+	core       zapcore.Core
+	generation int
+	lock       *sync.Mutex
+}
+
+func (c *switchCore) current() zapcore.Core {
+	baseLock.RLock()
+	base, generation := baseCore, baseGeneration
+	baseLock.RUnlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.core == nil || c.generation != generation {
+		c.core = base.With(c.fields)
+		c.generation = generation
 	}
 
-	return logger, nil
+	return c.core
+}
+
+func (c *switchCore) Enabled(level zapcore.Level) bool {
+	return c.current().Enabled(level)
+}
+
+func (c *switchCore) With(fields []zapcore.Field) zapcore.Core {
+	combined := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	combined = append(combined, c.fields...)
+	combined = append(combined, fields...)
+
+	return &switchCore{
+		fields: combined,
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (c *switchCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(entry, checked)
+}
+
+func (c *switchCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(entry, fields)
+}
+
+func (c *switchCore) Sync() error {
+	return c.current().Sync()
 }